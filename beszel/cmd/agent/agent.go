@@ -3,10 +3,13 @@ package main
 import (
 	"beszel"
 	"beszel/internal/agent"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 )
 
 func main() {
@@ -37,5 +40,11 @@ func main() {
 		addr = portEnvVar
 	}
 
-	agent.NewAgent().Run(pubKey, addr)
+	// stop on ctrl-c or docker stop, letting in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := agent.NewAgent().Run(ctx, pubKey, addr); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -3,39 +3,103 @@ package agent
 
 import (
 	"beszel"
+	"beszel/internal/entities/container"
 	"beszel/internal/entities/system"
 	"context"
 	"log/slog"
+	"net/http"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/shirou/gopsutil/v4/common"
+	"github.com/shirou/gopsutil/v4/cpu"
 )
 
 type Agent struct {
-	debug            bool                       // true if LOG_LEVEL is set to debug
-	zfs              bool                       // true if system has arcstats
-	memCalc          string                     // Memory calculation formula
-	fsNames          []string                   // List of filesystem device names being monitored
-	fsStats          map[string]*system.FsStats // Keeps track of disk stats for each filesystem
-	netInterfaces    map[string]struct{}        // Stores all valid network interfaces
-	netIoStats       system.NetIoStats          // Keeps track of bandwidth usage
-	dockerManager    *dockerManager             // Manages Docker API requests
-	sensorsContext   context.Context            // Sensors context to override sys location
-	sensorsWhitelist map[string]struct{}        // List of sensors to monitor
-	systemInfo       system.Info                // Host system info
-	gpuManager       *GPUManager                // Manages GPU data
+	debug            bool                          // true if LOG_LEVEL is set to debug
+	zfs              bool                          // true if system has arcstats
+	psi              bool                          // true if the kernel exposes /proc/pressure
+	memCalc          string                        // Memory calculation formula
+	fsNames          []string                      // List of filesystem device names being monitored
+	fsStats          map[string]*system.FsStats    // Keeps track of disk stats for each filesystem
+	netInterfaces    map[string]struct{}           // Stores all valid network interfaces
+	nicFilter        nicFilter                     // Interfaces listed in NICS, NICS_INCLUDE, and NICS_EXCLUDE
+	netIoStats       system.NetIoStats             // Keeps track of bandwidth usage
+	netNsStats       map[string]*system.NetIoStats // Keeps track of bandwidth usage in network namespaces
+	dockerManager    *dockerManager                // Manages Docker API requests
+	containerd       *containerdManager            // Reports containers run by containerd (nil if disabled)
+	sensorsContext   context.Context               // Sensors context to override sys location
+	sensorsWhitelist map[string]struct{}           // List of sensors to monitor
+	sensorLabels     map[string]string             // Names to report sensors under, by sensor key
+	systemInfo       system.Info                   // Host system info
+	gpuManager       *GPUManager                   // Manages GPU data
+	memStatsTime     time.Time                     // Last time the agent's memory stats were read
+	diskUsageTimeout time.Duration                 // Per-mount timeout for disk usage queries (0 = synchronous)
+	diskUsageSem     chan struct{}                 // Limits concurrent disk usage queries
+	diskUsagePending sync.Map                      // Mountpoints with a disk usage query in flight
+	memBandwidth     *memBandwidthStats            // Previous memory bandwidth counters (nil if disabled)
+	collectors       map[string]struct{}           // Collectors enabled by COLLECTORS env var (nil = defaults)
+	disabled         map[string]struct{}           // Collectors turned off by DISABLE_<NAME> env vars
+	ipmiManager      *ipmiManager                  // Collects IPMI sensor data (nil if disabled)
+	userStatsManager *userStatsManager             // Aggregates process usage by user (nil if disabled)
+	dnsProbe         *dnsProbe                     // Periodically resolves DNS_PROBE (nil if disabled)
+	tempRange        [2]float64                    // Temperatures outside this range are dropped
+	ratePrecision    int                           // Decimal places of host network and disk rates
+	baseUnits        bool                          // Whether flattened stats use bytes instead of GB / MB
+	remoteManager    *remoteManager                // Relays stats from remote agents (nil if disabled)
+	customMetrics    map[string]string             // Custom metric names and file paths from CUSTOM_METRICS
+	customPending    sync.Map                      // Custom metric paths with a read in flight
+	staleThreshold   time.Duration                 // Age at which cached sections are reported as stale
+	events           *eventTracker                 // Changes to event-like fields not yet sent to the hub
+	lastCpu          float64                       // Last successfully read cpu percent
+	libvirtManager   *libvirtManager               // Reports libvirt VM stats (nil if disabled)
+	rateWindow       *rateWindow                   // Computes rates over RATE_WINDOW (nil if disabled)
+	publicIpManager  *publicIpManager              // Looks up the public IP (nil if disabled)
+	redactor         *redactor                     // Redacts fields before sending (nil if disabled)
+	latencyManager   *diskLatencyManager           // Samples disk p99 latency with bpftrace (nil if disabled)
+	jails            bool                          // Whether to report FreeBSD jails
+	polls            *pollTracker                  // Time of the last request from the hub
+	systemdManager   *systemStateManager           // Checks systemd's overall state (nil if disabled)
+	perCore          bool                          // Whether to report usage of each cpu core
+	prevCpuTimes     *cpu.TimesStat                // Cpu times at the last collection, for iowait and steal
+	prevSwitches     switchCounters                // Context switch and interrupt counters at the last collection
+	prevSwap         swapCounters                  // Pages swapped in and out at the last collection
+	smartManager     *smartManager                 // Reads SMART health with smartctl (nil if disabled)
+	processManager   *processStatsManager          // Top processes (nil if disabled)
+	netIfaceStats    map[string]system.NetIoStats  // Previous counters of each network interface
+	connTracker      *connectionTracker            // Counts connections by state (nil if disabled)
+	netInfoTime      time.Time                     // Time interface link settings were last read
+	deviceRefresh    time.Duration                 // How often to re-discover filesystems and interfaces (0 = never)
+	fsRefreshTime    time.Time                     // Time filesystems were last discovered
+	netRefreshTime   time.Time                     // Time network interfaces were last discovered
+	collectMutex     sync.Mutex                    // Serializes collections from the SSH and HTTP servers
+	sessionMutex     sync.RWMutex                  // Read locked by each SSH session, locked at shutdown
+	httpServers      []*http.Server                // Optional HTTP and Prometheus servers
+	statsCache       *statsCache                   // Last collected stats, reused for rapid requests
 }
 
 func NewAgent() *Agent {
 	return &Agent{
 		sensorsContext: context.Background(),
+		tempRange:      [2]float64{-10, 150},
+		ratePrecision:  2,
+		deviceRefresh:  5 * time.Minute,
+		staleThreshold: 2 * time.Minute,
+		events:         newEventTracker(),
+		polls:          newPollTracker(),
+		statsCache:     &statsCache{},
 		memCalc:        os.Getenv("MEM_CALC"),
 		fsStats:        make(map[string]*system.FsStats),
 	}
 }
 
-func (a *Agent) Run(pubKey []byte, addr string) {
+// Starts collection and serves stats until ctx is cancelled. In-flight requests
+// finish before it returns.
+func (a *Agent) Run(ctx context.Context, pubKey []byte, addr string) error {
 	// Set up slog with a log level determined by the LOG_LEVEL env var
 	if logLevelStr, exists := os.LookupEnv("LOG_LEVEL"); exists {
 		switch strings.ToLower(logLevelStr) {
@@ -51,6 +115,11 @@ func (a *Agent) Run(pubKey []byte, addr string) {
 
 	slog.Debug(beszel.Version)
 
+	// sensor reads are cancelled with the agent
+	sensorsContext, cancelSensors := context.WithCancel(ctx)
+	defer cancelSensors()
+	a.sensorsContext = sensorsContext
+
 	// Set sensors context (allows overriding sys location for sensors)
 	if sysSensors, exists := os.LookupEnv("SYS_SENSORS"); exists {
 		slog.Info("SYS_SENSORS", "path", sysSensors)
@@ -69,40 +138,320 @@ func (a *Agent) Run(pubKey []byte, addr string) {
 		}
 	}
 
+	// Set friendly names of sensors, as key=name pairs
+	if labels, exists := os.LookupEnv("SENSORS_LABELS"); exists {
+		a.sensorLabels = make(map[string]string)
+		for _, label := range strings.Split(labels, ",") {
+			key, name, found := strings.Cut(strings.TrimSpace(label), "=")
+			if !found || key == "" || name == "" {
+				slog.Warn("Invalid SENSORS_LABELS entry", "value", label)
+				continue
+			}
+			a.sensorLabels[key] = name
+		}
+	}
+
+	// Set plausible temperature range
+	for i, key := range []string{"TEMP_MIN", "TEMP_MAX"} {
+		if val, exists := os.LookupEnv(key); exists {
+			if t, err := strconv.ParseFloat(val, 64); err == nil {
+				a.tempRange[i] = t
+			} else {
+				slog.Warn("Invalid "+key, "value", val)
+			}
+		}
+	}
+
+	// Set decimal places of host network and disk rates
+	if val, exists := os.LookupEnv("PRECISION"); exists {
+		if n, err := strconv.Atoi(val); err == nil && n >= 2 && n <= maxRatePrecision {
+			a.ratePrecision = n
+		} else {
+			slog.Warn("Invalid PRECISION", "value", val, "min", 2, "max", maxRatePrecision)
+		}
+	}
+
+	// Set units of flattened stats (the hub always receives GB / MB)
+	if units, exists := os.LookupEnv("UNITS"); exists {
+		switch strings.ToLower(units) {
+		case "bytes":
+			a.baseUnits = true
+		case "", "default":
+		default:
+			slog.Warn("Invalid UNITS", "value", units)
+		}
+	}
+
+	// Set how often filesystems and network interfaces are re-discovered
+	if t, set := os.LookupEnv("DEVICE_REFRESH_INTERVAL"); set {
+		if interval, err := time.ParseDuration(t); err == nil && interval >= 0 {
+			a.deviceRefresh = interval
+		} else {
+			slog.Warn("Invalid DEVICE_REFRESH_INTERVAL", "value", t)
+		}
+	}
+
+	// Set age at which cached data is flagged as stale
+	if t, set := os.LookupEnv("STALE_THRESHOLD"); set {
+		if threshold, err := time.ParseDuration(t); err == nil {
+			a.staleThreshold = threshold
+		} else {
+			slog.Warn("Invalid STALE_THRESHOLD", "err", err)
+		}
+	}
+
+	// Set trailing window for rates
+	a.rateWindow = newRateWindow()
+
+	// Set how long collected stats are reused
+	a.statsCache = newStatsCache()
+
+	// Set enabled collectors
+	if err := a.initializeCollectors(); err != nil {
+		slog.Error("Invalid COLLECTORS", "err", err)
+		os.Exit(1)
+	}
+
 	// initialize system info / docker manager
 	a.initializeSystemInfo()
-	a.initializeDiskInfo()
-	a.initializeNetIoStats()
-	a.dockerManager = newDockerManager(a)
+	if a.optionalCollectorEnabled("topology", "CPU_TOPOLOGY") {
+		a.systemInfo.CpuTopology = getCpuTopology()
+	}
+	if a.collectorEnabled("disk") {
+		a.initializeDiskInfo()
+		if t, set := os.LookupEnv("DISK_USAGE_TIMEOUT"); set {
+			timeout, err := time.ParseDuration(t)
+			if err != nil {
+				slog.Error("Invalid DISK_USAGE_TIMEOUT", "err", err)
+				os.Exit(1)
+			}
+			slog.Info("DISK_USAGE_TIMEOUT", "timeout", timeout)
+			a.diskUsageTimeout = timeout
+			a.diskUsageSem = make(chan struct{}, 4)
+		}
+	}
+	if a.collectorEnabled("net") {
+		a.initializeNetIoStats()
+	}
+	if a.collectorEnabled("netns") {
+		a.initializeNetNsStats()
+	}
+	if a.collectorEnabled("custom") {
+		a.initializeCustomMetrics()
+	}
+	a.perCore = a.optionalCollectorEnabled("percore", "TRACK_PER_CORE")
+	if threads := max(a.systemInfo.Threads, a.systemInfo.Cores); a.perCore && threads > maxPerCoreCount {
+		slog.Warn("Per core cpu limited", "cores", threads, "reported", maxPerCoreCount)
+	}
+	if a.optionalCollectorEnabled("membw", "MEM_BANDWIDTH") {
+		a.initializeMemBandwidth()
+	}
+	runtimeName := containerRuntime()
+	if a.collectorEnabled("docker") && (runtimeName == "" || runtimeName == "containerd") {
+		a.containerd = newContainerdManager(max(a.systemInfo.Threads, a.systemInfo.Cores))
+		if a.containerd == nil && runtimeName == "containerd" {
+			slog.Warn("containerd task directory not found", "dir", containerdTaskDir)
+		}
+	}
+	if a.collectorEnabled("docker") && runtimeName != "containerd" {
+		a.dockerManager = newDockerManager(a, runtimeName)
+		a.dockerManager.rateWindow = a.rateWindow
+		if a.optionalCollectorEnabled("updates", "IMAGE_UPDATES") {
+			a.dockerManager.imageUpdates = newImageUpdateChecker(a.dockerManager.client)
+		}
+		a.dockerManager.cpuConfig = a.optionalCollectorEnabled("limits", "CONTAINER_CPU_CONFIG")
+		a.dockerManager.sockets = a.optionalCollectorEnabled("sockets", "CONTAINER_SOCKETS")
+		a.dockerManager.memDetail = a.optionalCollectorEnabled("memdetail", "CONTAINER_MEM_DETAIL")
+		a.dockerManager.grouper = newReplicaGrouper()
+		if a.collectorEnabled("logs") {
+			a.dockerManager.logErrors = newLogErrorCounter(a.dockerManager.client)
+		}
+		if a.optionalCollectorEnabled("dockerdf", "DOCKER_DISK_USAGE") {
+			a.dockerManager.diskUsage = newDockerDiskUsageManager(ctx, a.dockerManager.client)
+		}
+	}
 
 	// initialize GPU manager
-	if gm, err := NewGPUManager(); err != nil {
+	if !a.collectorEnabled("gpu") {
+		slog.Debug("GPU collector disabled")
+	} else if gm, err := NewGPUManager(); err != nil {
 		slog.Debug("GPU", "err", err)
 	} else {
 		a.gpuManager = gm
 	}
 
+	// initialize IPMI manager
+	if a.optionalCollectorEnabled("ipmi", "IPMI") {
+		a.ipmiManager = newIpmiManager(ctx)
+	}
+
+	// initialize per-user stats
+	if a.optionalCollectorEnabled("users", "TOP_USERS") {
+		a.userStatsManager = newUserStatsManager(ctx, max(a.systemInfo.Threads, a.systemInfo.Cores))
+	}
+
+	// initialize connection counts
+	if a.optionalCollectorEnabled("connections", "TRACK_CONNECTIONS") {
+		a.connTracker = newConnectionTracker(ctx)
+	}
+
+	// initialize top process stats
+	if a.optionalCollectorEnabled("processes", "TOP_PROCESSES") {
+		a.processManager = newProcessStatsManager(ctx, max(a.systemInfo.Threads, a.systemInfo.Cores))
+	}
+
+	// initialize libvirt VM stats
+	if a.optionalCollectorEnabled("vms", "LIBVIRT") {
+		a.libvirtManager = newLibvirtManager(max(a.systemInfo.Threads, a.systemInfo.Cores))
+	}
+
+	// jails are listed with containers
+	a.jails = runtime.GOOS == "freebsd" && a.collectorEnabled("jails")
+
+	// initialize disk latency sampling
+	if a.optionalCollectorEnabled("disklatency", "DISK_LATENCY") {
+		a.latencyManager = newDiskLatencyManager(ctx)
+	}
+
+	// initialize SMART health checks
+	if a.optionalCollectorEnabled("smart", "COLLECT_SMART") {
+		a.smartManager = newSmartManager(ctx)
+	}
+
+	// initialize systemd state check
+	if a.optionalCollectorEnabled("systemd", "SYSTEMD_STATE") {
+		a.systemdManager = newSystemStateManager(ctx)
+	}
+
+	// initialize public IP lookup
+	if a.optionalCollectorEnabled("publicip", "PUBLIC_IP") {
+		a.publicIpManager = newPublicIpManager(ctx)
+	}
+
+	// initialize DNS probe
+	if host, exists := os.LookupEnv("DNS_PROBE"); exists && host != "" && a.collectorEnabled("dns") {
+		a.dnsProbe = newDnsProbe(ctx, host)
+	}
+
+	// initialize remote agent relay
+	a.remoteManager = newRemoteManager()
+
+	// initialize redaction rules
+	a.redactor = newRedactor()
+
+	// warn if the hub stops requesting stats
+	a.polls.watch()
+
+	// collect on a fixed interval if INTERVAL is set
+	a.startScheduler(ctx)
+
 	// if debugging, print stats
 	if a.debug {
 		slog.Debug("Stats", "data", a.gatherStats())
 	}
 
-	a.startServer(pubKey, addr)
+	a.startPrometheusServer()
+	a.startHttpServer()
+
+	return a.startServer(ctx, pubKey, addr)
 }
 
+// Collects stats for the hub, including events since the last request, and
+// clears the out of memory kills they include
 func (a *Agent) gatherStats() system.CombinedData {
+	a.collectMutex.Lock()
+	systemData := a.latestStats()
+	// acknowledged under the lock so a collection can't change what's cleared
+	if a.dockerManager != nil {
+		a.dockerManager.oom.ack()
+	}
+	a.collectMutex.Unlock()
+	systemData.Events = a.events.drain()
+	return systemData
+}
+
+// Collects stats without draining pending events or out of memory kills, so
+// other consumers (e.g. the Prometheus endpoint) don't take data meant for the hub
+func (a *Agent) collectStats() system.CombinedData {
+	a.collectMutex.Lock()
+	defer a.collectMutex.Unlock()
+	return a.latestStats()
+}
+
+// Returns the cached stats if fresh, otherwise collects them. collectMutex must be held.
+func (a *Agent) latestStats() system.CombinedData {
+	if cached, ok := a.statsCache.get(); ok {
+		slog.Debug("Using cached stats")
+		return cached
+	}
+	return a.collectFreshStats()
+}
+
+// Collects stats without checking the cache. collectMutex must be held.
+func (a *Agent) collectFreshStats() system.CombinedData {
 	slog.Debug("Getting stats")
+	// query docker while system stats are collected
+	var containerStats []*container.Stats
+	var dockerErr error
+	var dockerWg sync.WaitGroup
+	if a.dockerManager != nil {
+		dockerWg.Add(1)
+		go func() {
+			defer dockerWg.Done()
+			containerStats, dockerErr = a.dockerManager.getDockerStats()
+		}()
+	}
 	systemData := system.CombinedData{
 		Stats: a.getSystemStats(),
 		Info:  a.systemInfo,
 	}
 	slog.Debug("System stats", "data", systemData)
+	dockerWg.Wait()
 	// add docker stats
-	if containerStats, err := a.dockerManager.getDockerStats(); err == nil {
-		systemData.Containers = containerStats
-		slog.Debug("Docker stats", "data", systemData.Containers)
-	} else {
-		slog.Debug("Error getting docker stats", "err", err)
+	if a.dockerManager != nil {
+		a.dockerManager.recordResult(dockerErr)
+		systemData.Info.Docker = a.dockerManager.getStatus()
+		if dockerErr == nil {
+			systemData.Containers = containerStats
+			containerStates := a.dockerManager.containerStates
+			systemData.Info.Containers = &containerStates
+			if a.dockerManager.diskUsage != nil {
+				systemData.Info.DockerDisk = a.dockerManager.diskUsage.getUsage()
+			}
+			slog.Debug("Docker stats", "data", systemData.Containers)
+		} else {
+			slog.Debug("Error getting docker stats", "err", dockerErr)
+		}
+	}
+	// add containerd containers
+	if a.containerd != nil {
+		if containers, err := a.containerd.getStats(); err == nil {
+			systemData.Containers = append(systemData.Containers, containers...)
+			var containerStates system.ContainerStates
+			if systemData.Info.Containers != nil {
+				containerStates = *systemData.Info.Containers
+			}
+			containerStates.Running += len(containers)
+			systemData.Info.Containers = &containerStates
+		} else {
+			slog.Debug("Error getting containerd stats", "err", err)
+		}
+	}
+	// add FreeBSD jails
+	if a.jails {
+		if jails, err := getJailStats(max(a.systemInfo.Threads, a.systemInfo.Cores)); err == nil {
+			systemData.Containers = append(systemData.Containers, jails...)
+		} else {
+			slog.Debug("Error getting jail stats", "err", err)
+		}
+	}
+	// add VM stats
+	if a.libvirtManager != nil {
+		if vms, err := a.libvirtManager.getStats(); err == nil {
+			systemData.VMs = vms
+		} else {
+			slog.Debug("Error getting VM stats", "err", err)
+		}
 	}
 	// add extra filesystems
 	systemData.Stats.ExtraFs = make(map[string]*system.FsStats)
@@ -112,5 +461,11 @@ func (a *Agent) gatherStats() system.CombinedData {
 		}
 	}
 	slog.Debug("Extra filesystems", "data", systemData.Stats.ExtraFs)
-	return systemData
+	// redact before events so event names match the redacted data
+	if a.redactor != nil {
+		a.redactor.apply(&systemData)
+	}
+	// record changes to event-like fields
+	a.trackEvents(&systemData)
+	return a.statsCache.set(systemData)
 }
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"math"
+	"path/filepath"
+	"strconv"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// Totals of one or more batteries, in µWh / µW or µAh / µA depending on the driver
+type batteryTotals struct {
+	now, full, rate float64
+}
+
+// Reads the combined charge of all system batteries (laptop or UPS) from sysfs.
+// Returns nil if there are none, e.g. on desktops and servers. Linux only.
+func getBattery() *system.Battery {
+	supplies, _ := filepath.Glob(filepath.Join(powerSupplyDir, "*"))
+	var battery system.Battery
+	var energy, charge batteryTotals
+	var capacitySum float64
+	count := 0
+	for _, dir := range supplies {
+		switch readSysfsString(filepath.Join(dir, "type")) {
+		case "Battery", "UPS":
+		default:
+			continue
+		}
+		// skip peripherals such as wireless mice
+		if readSysfsString(filepath.Join(dir, "scope")) == "Device" {
+			continue
+		}
+		capacity, err := strconv.ParseFloat(readSysfsString(filepath.Join(dir, "capacity")), 64)
+		if err != nil {
+			continue
+		}
+		count++
+		capacitySum += capacity
+		if readSysfsString(filepath.Join(dir, "status")) == "Charging" {
+			battery.Charging = true
+		}
+		energy.add(dir, "energy_now", "energy_full", "power_now")
+		charge.add(dir, "charge_now", "charge_full", "current_now")
+	}
+	if count == 0 {
+		return nil
+	}
+	// weight by capacity when the driver reports it, so a small
+	// secondary battery doesn't skew the percentage
+	totals := energy
+	if totals.full == 0 {
+		totals = charge
+	}
+	if totals.full > 0 {
+		battery.Percent = twoDecimals(totals.now / totals.full * 100)
+	} else {
+		battery.Percent = twoDecimals(capacitySum / float64(count))
+	}
+	if !battery.Charging && totals.rate > 0 {
+		battery.TimeToEmpty = uint32(math.Round(totals.now / totals.rate * 60))
+	}
+	return &battery
+}
+
+// Adds the battery's current, full, and rate values to the totals
+func (t *batteryTotals) add(dir, nowFile, fullFile, rateFile string) {
+	now, err := strconv.ParseFloat(readSysfsString(filepath.Join(dir, nowFile)), 64)
+	if err != nil {
+		return
+	}
+	full, err := strconv.ParseFloat(readSysfsString(filepath.Join(dir, fullFile)), 64)
+	if err != nil {
+		return
+	}
+	rate, _ := strconv.ParseFloat(readSysfsString(filepath.Join(dir, rateFile)), 64)
+	t.now += now
+	t.full += full
+	t.rate += math.Abs(rate)
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Root of the cgroup v2 unified hierarchy
+const cgroupRoot = "/sys/fs/cgroup"
+
+// Returns the cgroup v2 directory for a docker container, or an empty string
+// if it can't be found (cgroup v1, or the host cgroup filesystem isn't visible)
+func containerCgroupDir(id string) string {
+	candidates := []string{
+		filepath.Join(cgroupRoot, "system.slice", "docker-"+id+".scope"), // systemd driver
+		filepath.Join(cgroupRoot, "docker", id),                          // cgroupfs driver
+	}
+	for _, dir := range candidates {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// Reads a single integer value from a cgroup file
+func readCgroupValue(dir, name string) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return value, err == nil
+}
+
+// Reads the value of a key from a flat keyed cgroup file such as memory.stat
+func readCgroupKeyedValue(dir, name, key string) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, found := strings.Cut(line, " "); found && k == key {
+			value, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Returns the cgroup v2 directory of a process, or an empty string if the
+// process doesn't exist or isn't in the unified hierarchy
+func processCgroupDir(pid int) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Example line: 0::/kubepods.slice/kubepods-pod1234.slice/cri-containerd-abcd.scope
+		if path, found := strings.CutPrefix(line, "0::"); found {
+			return filepath.Join(cgroupRoot, path)
+		}
+	}
+	return ""
+}
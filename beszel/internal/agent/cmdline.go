@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Returns the kernel boot parameters from /proc/cmdline selected by the
+// KERNEL_CMDLINE env var: "true" for the full command line, or a comma
+// separated list of parameter names (e.g. isolcpus,hugepages,mitigations).
+// Returns an empty string if unset or not on Linux.
+func getKernelCmdline() string {
+	value, exists := os.LookupEnv("KERNEL_CMDLINE")
+	if !exists {
+		return ""
+	}
+	data, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		return ""
+	}
+	cmdline := strings.TrimSpace(string(data))
+	if all, err := strconv.ParseBool(value); err == nil {
+		if all {
+			return cmdline
+		}
+		return ""
+	}
+	allowed := strings.Split(value, ",")
+	for i := range allowed {
+		allowed[i] = strings.TrimSpace(allowed[i])
+	}
+	var params []string
+	for _, param := range strings.Fields(cmdline) {
+		name, _, _ := strings.Cut(param, "=")
+		if slices.Contains(allowed, name) {
+			params = append(params, param)
+		}
+	}
+	return strings.Join(params, " ")
+}
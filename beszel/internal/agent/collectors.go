@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Collectors that can be listed in the COLLECTORS env var
+var collectorNames = []string{
+	"battery",
+	"connections",
+	"conntrack",
+	"cpu",
+	"custom",
+	"disk",
+	"disklatency",
+	"dns",
+	"docker",
+	"dockerdf",
+	"gpu",
+	"ipmi",
+	"jails",
+	"limits",
+	"logs",
+	"mem",
+	"membw",
+	"memdetail",
+	"net",
+	"netns",
+	"percore",
+	"powercap",
+	"processes",
+	"psi",
+	"publicip",
+	"runtime",
+	"sensors",
+	"smart",
+	"sockets",
+	"systemd",
+	"topology",
+	"updates",
+	"users",
+	"vms",
+}
+
+// Other names accepted after DISABLE_, e.g. DISABLE_TEMPS
+var collectorAliases = map[string]string{
+	"network": "net",
+	"temps":   "sensors",
+}
+
+// Parses the COLLECTORS env var. If set, only the listed collectors run.
+// DISABLE_<NAME> (e.g. DISABLE_DOCKER=true) turns off a single collector
+// either way.
+func (a *Agent) initializeCollectors() error {
+	a.initializeDisabledCollectors()
+	collectors, exists := os.LookupEnv("COLLECTORS")
+	if !exists {
+		return nil
+	}
+	valid := make(map[string]struct{}, len(collectorNames))
+	for _, name := range collectorNames {
+		valid[name] = struct{}{}
+	}
+	a.collectors = make(map[string]struct{})
+	for _, name := range strings.Split(collectors, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := valid[name]; !ok {
+			return fmt.Errorf("unknown collector %q (valid: %s)", name, strings.Join(collectorNames, ", "))
+		}
+		a.collectors[name] = struct{}{}
+	}
+	return nil
+}
+
+// Parses the DISABLE_<NAME> env var of each collector and alias
+func (a *Agent) initializeDisabledCollectors() {
+	names := make(map[string]string, len(collectorNames)+len(collectorAliases))
+	for _, name := range collectorNames {
+		names[name] = name
+	}
+	for alias, name := range collectorAliases {
+		names[alias] = name
+	}
+	for env, name := range names {
+		envVar := "DISABLE_" + strings.ToUpper(env)
+		value, exists := os.LookupEnv(envVar)
+		if !exists {
+			continue
+		}
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			slog.Warn("Invalid "+envVar, "value", value)
+			continue
+		}
+		if !disabled {
+			continue
+		}
+		if a.disabled == nil {
+			a.disabled = make(map[string]struct{})
+		}
+		a.disabled[name] = struct{}{}
+		slog.Info("Collector disabled", "collector", name)
+	}
+}
+
+// Returns true if the named collector should run
+func (a *Agent) collectorEnabled(name string) bool {
+	if _, disabled := a.disabled[name]; disabled {
+		return false
+	}
+	if a.collectors == nil {
+		return true
+	}
+	_, ok := a.collectors[name]
+	return ok
+}
+
+// Returns true if an opt-in collector should run. When COLLECTORS is set it
+// decides, otherwise the collector's own env var must be set and not false.
+func (a *Agent) optionalCollectorEnabled(name, envVar string) bool {
+	if _, disabled := a.disabled[name]; disabled {
+		return false
+	}
+	if a.collectors != nil {
+		return a.collectorEnabled(name)
+	}
+	value, exists := os.LookupEnv(envVar)
+	if !exists {
+		return false
+	}
+	// non-boolean values (e.g. a count) also enable the collector
+	enabled, err := strconv.ParseBool(value)
+	return enabled || err != nil
+}
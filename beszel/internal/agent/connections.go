@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	psutilNet "github.com/shirou/gopsutil/v4/net"
+)
+
+// Listing connections walks every process's open files, so it runs in the
+// background rather than on each stats request
+const connectionsDefaultInterval = time.Minute
+
+type connectionTracker struct {
+	counts  map[string]int // Connections by lowercase state, e.g. established
+	updated time.Time      // Time of the last successful count
+	warned  bool           // Whether a partial read has been logged
+	mutex   sync.Mutex
+}
+
+// Returns a new connectionTracker and starts counting in the background
+func newConnectionTracker(ctx context.Context) *connectionTracker {
+	interval := connectionsDefaultInterval
+	if val, exists := os.LookupEnv("CONNECTIONS_INTERVAL"); exists {
+		if d, err := time.ParseDuration(val); err == nil && d >= time.Second {
+			interval = d
+		} else {
+			slog.Warn("Invalid CONNECTIONS_INTERVAL", "value", val)
+		}
+	}
+	slog.Info("TRACK_CONNECTIONS", "interval", interval)
+	ct := &connectionTracker{}
+	go func() {
+		for {
+			ct.count()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return ct
+}
+
+// Counts TCP connections by state and UDP sockets as "udp"
+func (ct *connectionTracker) count() {
+	conns, err := psutilNet.Connections("inet")
+	// without root some process files can't be read, but sockets are still
+	// listed from /proc/net, so keep partial results
+	if err != nil && len(conns) == 0 {
+		slog.Warn("Error listing connections", "err", err)
+		return
+	}
+	if err != nil && !ct.warned {
+		slog.Debug("Connections may be incomplete", "err", err)
+		ct.warned = true
+	}
+	counts := make(map[string]int)
+	for _, conn := range conns {
+		if conn.Status == "" || conn.Status == "NONE" {
+			counts["udp"]++
+			continue
+		}
+		counts[strings.ToLower(conn.Status)]++
+	}
+	ct.mutex.Lock()
+	ct.counts = counts
+	ct.updated = time.Now()
+	ct.mutex.Unlock()
+}
+
+// Returns a copy of the latest counts
+func (ct *connectionTracker) getCounts() map[string]int {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	return maps.Clone(ct.counts)
+}
+
+// Returns the time of the last successful count
+func (ct *connectionTracker) lastUpdate() time.Time {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	return ct.updated
+}
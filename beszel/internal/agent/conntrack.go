@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"strconv"
+)
+
+const conntrackDir = "/proc/sys/net/netfilter/"
+
+// Sets the netfilter connection tracking table usage. Leaves the fields
+// at zero when the nf_conntrack module isn't loaded.
+func setConntrackStats(systemStats *system.Stats) {
+	count, err := strconv.ParseUint(readSysfsString(conntrackDir+"nf_conntrack_count"), 10, 64)
+	if err != nil {
+		return
+	}
+	limit, err := strconv.ParseUint(readSysfsString(conntrackDir+"nf_conntrack_max"), 10, 64)
+	if err != nil || limit == 0 {
+		return
+	}
+	systemStats.ConntrackCount = count
+	systemStats.ConntrackMax = limit
+	systemStats.ConntrackPct = twoDecimals(float64(count) / float64(limit) * 100)
+}
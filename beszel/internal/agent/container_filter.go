@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Limits which containers are reported
+type containerFilter struct {
+	labels map[string]string // key -> value, or empty value to match any value
+	names  []string          // Substrings of the container name
+}
+
+// Parses CONTAINER_FILTER_LABEL (comma separated key=value or key) and
+// CONTAINER_FILTER_NAME (comma separated substrings). Returns nil if neither is set.
+func newContainerFilter() *containerFilter {
+	var cf containerFilter
+	if val, exists := os.LookupEnv("CONTAINER_FILTER_LABEL"); exists {
+		for _, pair := range strings.Split(val, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if key == "" {
+				continue
+			}
+			if cf.labels == nil {
+				cf.labels = make(map[string]string)
+			}
+			cf.labels[key] = value
+		}
+	}
+	if val, exists := os.LookupEnv("CONTAINER_FILTER_NAME"); exists {
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cf.names = append(cf.names, name)
+			}
+		}
+	}
+	if cf.labels == nil && cf.names == nil {
+		return nil
+	}
+	slog.Info("Filtering containers", "labels", cf.labels, "names", cf.names)
+	return &cf
+}
+
+// Returns true if the container matches any of the labels and any of the names.
+// Filters that aren't set always match.
+func (cf *containerFilter) match(ctr container.ApiInfo) bool {
+	if cf.labels != nil && !cf.matchLabels(ctr.Labels) {
+		return false
+	}
+	if cf.names == nil {
+		return true
+	}
+	for _, name := range ctr.Names {
+		name = strings.TrimPrefix(name, "/")
+		for _, substr := range cf.names {
+			if strings.Contains(name, substr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if any of the filter labels is set on the container
+func (cf *containerFilter) matchLabels(labels map[string]string) bool {
+	for key, want := range cf.labels {
+		if value, ok := labels[key]; ok && (want == "" || value == want) {
+			return true
+		}
+	}
+	return false
+}
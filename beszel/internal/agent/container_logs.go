@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum log lines fetched per container per update
+	containerLogTail = 1000
+	// Maximum log bytes read per container per update
+	containerLogMaxBytes = 1 << 20
+	// Time to wait for logs before retrying the window at the next update
+	containerLogTimeout    = 10 * time.Second
+	defaultLogErrorPattern = `(?i)\b(error|exception|fatal|panic)\b`
+)
+
+// Counts log lines matching an error pattern for a configured set of containers.
+// Logs are fetched in the background so a slow daemon doesn't hold up the update.
+type logErrorCounter struct {
+	client     *http.Client
+	containers map[string]struct{}  // Container names to check
+	pattern    *regexp.Regexp       // Lines matching this are counted as errors
+	lastCheck  map[string]time.Time // End of the last window checked for each container id
+	rates      map[string]float64   // Matching lines per minute in the last window, by container id
+	fetching   map[string]struct{}  // Container ids with a log fetch in progress
+	mutex      sync.Mutex
+}
+
+// Returns a new logErrorCounter for the containers in CONTAINER_LOG_ERRORS,
+// or nil if it's not set or the pattern is invalid
+func newLogErrorCounter(client *http.Client) *logErrorCounter {
+	names, exists := os.LookupEnv("CONTAINER_LOG_ERRORS")
+	if !exists || names == "" {
+		return nil
+	}
+	expr := defaultLogErrorPattern
+	if val, exists := os.LookupEnv("CONTAINER_LOG_PATTERN"); exists && val != "" {
+		expr = val
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		slog.Error("Invalid CONTAINER_LOG_PATTERN", "err", err)
+		return nil
+	}
+	lc := &logErrorCounter{
+		client:     client,
+		containers: make(map[string]struct{}),
+		pattern:    pattern,
+		lastCheck:  make(map[string]time.Time),
+		rates:      make(map[string]float64),
+		fetching:   make(map[string]struct{}),
+	}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			lc.containers[name] = struct{}{}
+		}
+	}
+	slog.Info("CONTAINER_LOG_ERRORS", "containers", names, "pattern", pattern)
+	return lc
+}
+
+// Returns matching log lines per minute from the latest completed check of the
+// container and starts the next check in the background. bool is false if the
+// container isn't configured or no check has completed yet.
+func (lc *logErrorCounter) errorRate(id, name string) (float64, bool) {
+	if _, ok := lc.containers[name]; !ok {
+		return 0, false
+	}
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	rate, ok := lc.rates[id]
+	if _, running := lc.fetching[id]; !running {
+		lc.fetching[id] = struct{}{}
+		go lc.fetch(id, name)
+	}
+	return rate, ok
+}
+
+// Counts matching lines logged since the end of the previous window. Windows
+// don't overlap, so a line is only counted once. The first call only sets the
+// start of the next window.
+func (lc *logErrorCounter) fetch(id, name string) {
+	now := time.Now()
+	lc.mutex.Lock()
+	since, checked := lc.lastCheck[id]
+	lc.mutex.Unlock()
+	var count int
+	var err error
+	if checked {
+		count, err = lc.countSince(id, since, now)
+	}
+
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	delete(lc.fetching, id)
+	if err != nil {
+		// keep the window start so the next check covers the missed lines
+		slog.Debug("Error getting container logs", "name", name, "err", err)
+		return
+	}
+	lc.lastCheck[id] = now
+	if minutes := now.Sub(since).Minutes(); checked && minutes > 0 {
+		lc.rates[id] = twoDecimals(float64(count) / minutes)
+	}
+}
+
+// Counts matching lines logged after since, up to and including until
+func (lc *logErrorCounter) countSince(id string, since, until time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerLogTimeout)
+	defer cancel()
+	// the API accepts fractional timestamps and both bounds are inclusive
+	url := "http://localhost/containers/" + id + "/logs?stdout=1&stderr=1&tail=" + strconv.Itoa(containerLogTail) +
+		"&since=" + logTimestamp(since.Add(time.Nanosecond)) + "&until=" + logTimestamp(until)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := lc.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("logs returned %s", resp.Status)
+	}
+	return lc.countErrors(io.LimitReader(resp.Body, containerLogMaxBytes)), nil
+}
+
+// Formats a time as seconds.nanoseconds for the logs API
+func logTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// Counts matching lines in a log stream. Containers without a TTY return
+// stdout and stderr multiplexed in frames with an 8 byte header
+// (stream type, 3 zero bytes, big endian payload size).
+func (lc *logErrorCounter) countErrors(r io.Reader) int {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(8)
+	multiplexed := err == nil && header[0] <= 2 && header[1] == 0 && header[2] == 0 && header[3] == 0
+	if !multiplexed {
+		return lc.countLines(br)
+	}
+	count := 0
+	frameHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(br, frameHeader); err != nil {
+			return count
+		}
+		frame := io.LimitReader(br, int64(binary.BigEndian.Uint32(frameHeader[4:])))
+		count += lc.countLines(frame)
+		// skip the rest of the frame if a line was too long to scan
+		io.Copy(io.Discard, frame)
+	}
+}
+
+func (lc *logErrorCounter) countLines(r io.Reader) int {
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if lc.pattern.Match(scanner.Bytes()) {
+			count++
+		}
+	}
+	return count
+}
+
+// Forgets containers that are no longer running
+func (lc *logErrorCounter) prune(validIds map[string]struct{}) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	for id := range lc.lastCheck {
+		if _, ok := validIds[id]; !ok {
+			delete(lc.lastCheck, id)
+			delete(lc.rates, id)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// Directory where containerd keeps the bundle of each running task, by namespace
+const containerdTaskDir = "/run/containerd/io.containerd.runtime.v2.task"
+
+// Returns the runtime set by CONTAINER_RUNTIME (docker, podman, or containerd),
+// or an empty string to use every runtime that is found
+func containerRuntime() string {
+	value, exists := os.LookupEnv("CONTAINER_RUNTIME")
+	if !exists {
+		return ""
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "docker", "podman", "containerd":
+		slog.Info("CONTAINER_RUNTIME", "runtime", value)
+		return value
+	}
+	slog.Warn("Invalid CONTAINER_RUNTIME", "value", value)
+	return ""
+}
+
+// Counters from the previous collection of a containerd task
+type containerdPrev struct {
+	cpu   uint64 // cpu.stat usage_usec
+	sent  uint64
+	recv  uint64
+	read  uint64
+	write uint64
+	taken time.Time
+}
+
+// Reports containers run directly by containerd (e.g. Kubernetes nodes) from
+// the task bundles and cgroup v2 files, so no containerd client is needed.
+// Needs /run/containerd mounted and pid: host when the agent runs in a container.
+type containerdManager struct {
+	cpuCount int
+	hostMem  uint64                    // Host memory in bytes, to ignore limits above it
+	prev     map[string]containerdPrev // Previous counters by task id
+}
+
+// Minimal OCI runtime spec from a task bundle's config.json
+type containerdSpec struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Returns a new containerdManager, or nil if containerd has no task directory
+func newContainerdManager(cpuCount int) *containerdManager {
+	if _, err := os.Stat(containerdTaskDir); err != nil {
+		slog.Debug("containerd", "err", err)
+		return nil
+	}
+	slog.Info("Reporting containerd containers")
+	cm := &containerdManager{
+		cpuCount: max(cpuCount, 1),
+		prev:     make(map[string]containerdPrev),
+	}
+	// memory.max may be larger than the host when no limit is set
+	if v, err := mem.VirtualMemory(); err == nil {
+		cm.hostMem = v.Total
+	}
+	return cm
+}
+
+// Returns stats for all running containerd tasks. Docker's own tasks (the moby
+// namespace) and Kubernetes pod sandboxes are skipped. Rates are since the
+// previous call, so a newly seen task reports zero cpu, network, and disk.
+func (cm *containerdManager) getStats() ([]*container.Stats, error) {
+	namespaces, err := os.ReadDir(containerdTaskDir)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var stats []*container.Stats
+	seen := make(map[string]struct{})
+	for _, ns := range namespaces {
+		if !ns.IsDir() || ns.Name() == "moby" {
+			continue
+		}
+		tasks, err := os.ReadDir(filepath.Join(containerdTaskDir, ns.Name()))
+		if err != nil {
+			continue
+		}
+		for _, task := range tasks {
+			id := task.Name()
+			ctr, ok := cm.taskStats(filepath.Join(containerdTaskDir, ns.Name(), id), id, now)
+			if !ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			stats = append(stats, ctr)
+		}
+	}
+	// forget tasks that stopped
+	for id := range cm.prev {
+		if _, ok := seen[id]; !ok {
+			delete(cm.prev, id)
+		}
+	}
+	return stats, nil
+}
+
+// Reads the stats of a single task. Returns false for sandboxes and tasks
+// that can't be read (e.g. stopped between listing and reading).
+func (cm *containerdManager) taskStats(bundle, id string, now time.Time) (*container.Stats, bool) {
+	var spec containerdSpec
+	if data, err := os.ReadFile(filepath.Join(bundle, "config.json")); err == nil {
+		json.Unmarshal(data, &spec)
+	}
+	if spec.Annotations["io.kubernetes.cri.container-type"] == "sandbox" {
+		return nil, false
+	}
+	pidFile := filepath.Join(bundle, "init.pid")
+	pidData, err := os.ReadFile(pidFile)
+	if err != nil {
+		return nil, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err != nil {
+		return nil, false
+	}
+	dir := processCgroupDir(pid)
+	if dir == "" {
+		slog.Debug("No cgroup v2 directory for containerd task", "id", id)
+		return nil, false
+	}
+
+	ctr := &container.Stats{
+		Name:  containerdName(spec.Annotations, id),
+		Image: imageName(spec.Annotations["io.kubernetes.cri.image-name"], ""),
+	}
+	if info, err := os.Stat(pidFile); err == nil {
+		ctr.Uptime = uint64(max(now.Sub(info.ModTime()).Seconds(), 0))
+	}
+
+	// memory, excluding inactive page cache like docker
+	usage, _ := readCgroupValue(dir, "memory.current")
+	inactive, _ := readCgroupKeyedValue(dir, "memory.stat", "inactive_file")
+	if inactive < usage {
+		usage -= inactive
+	}
+	ctr.Mem = bytesToMegabytes(float64(usage))
+	if limit, ok := readCgroupValue(dir, "memory.max"); ok && limit > 0 && (cm.hostMem == 0 || limit < cm.hostMem) {
+		ctr.MemLimit = bytesToMegabytes(float64(limit))
+		ctr.MemPct = twoDecimals(float64(usage) / float64(limit) * 100)
+	}
+	swap, _ := readCgroupValue(dir, "memory.swap.current")
+	ctr.SwapUsed = bytesToMegabytes(float64(swap))
+
+	// containers in a pod share its network namespace, so each reports the pod's traffic
+	cur := containerdPrev{taken: now}
+	cur.cpu, _ = readCgroupKeyedValue(dir, "cpu.stat", "usage_usec")
+	if data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "net", "dev")); err == nil {
+		cur.sent, cur.recv = parseNetDev(data)
+	}
+	cur.read, cur.write = readCgroupIoBytes(dir)
+
+	if prev, ok := cm.prev[id]; ok {
+		if seconds := now.Sub(prev.taken).Seconds(); seconds > 0 {
+			if delta, ok := counterDelta(prev.cpu, cur.cpu); ok {
+				ctr.Cpu = smallDecimals(float64(delta) / (seconds * 1e6 * float64(cm.cpuCount)) * 100)
+			}
+			sent, sentOk := counterDelta(prev.sent, cur.sent)
+			recv, recvOk := counterDelta(prev.recv, cur.recv)
+			if sentOk && recvOk {
+				ctr.NetworkSent = smallMegabytesPerSecond(float64(sent) / seconds)
+				ctr.NetworkRecv = smallMegabytesPerSecond(float64(recv) / seconds)
+			}
+			read, readOk := counterDelta(prev.read, cur.read)
+			write, writeOk := counterDelta(prev.write, cur.write)
+			if readOk && writeOk {
+				ctr.DiskReadPs = smallMegabytesPerSecond(float64(read) / seconds)
+				ctr.DiskWritePs = smallMegabytesPerSecond(float64(write) / seconds)
+			}
+		}
+	}
+	cm.prev[id] = cur
+	return ctr, true
+}
+
+// Returns a readable name from the task annotations: pod/container for
+// Kubernetes, the nerdctl name, or the short task id
+func containerdName(annotations map[string]string, id string) string {
+	if name := annotations["io.kubernetes.cri.container-name"]; name != "" {
+		if pod := annotations["io.kubernetes.cri.sandbox-name"]; pod != "" {
+			return pod + "/" + name
+		}
+		return name
+	}
+	if name := annotations["nerdctl/name"]; name != "" {
+		return name
+	}
+	return id[:min(len(id), 12)]
+}
+
+// Sums bytes read and written on all devices in the cgroup's io.stat
+func readCgroupIoBytes(dir string) (read, write uint64) {
+	data, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return 0, 0
+	}
+	// Example line: 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	for _, field := range strings.Fields(string(data)) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			continue
+		}
+		n, _ := strconv.ParseUint(value, 10, 64)
+		switch key {
+		case "rbytes":
+			read += n
+		case "wbytes":
+			write += n
+		}
+	}
+	return read, write
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum time to wait for a custom metric file to be read
+const customMetricTimeout = time.Second
+
+// Directories that custom metric files may be read from
+var customMetricPrefixes = []string{"/proc/", "/sys/"}
+
+// Parses the CUSTOM_METRICS env var, a comma separated list of name=path pairs.
+// Paths that don't exist or resolve outside of /proc and /sys are rejected.
+func (a *Agent) initializeCustomMetrics() {
+	metrics, exists := os.LookupEnv("CUSTOM_METRICS")
+	if !exists {
+		return
+	}
+	a.customMetrics = make(map[string]string)
+	for _, metric := range strings.Split(metrics, ",") {
+		name, path, found := strings.Cut(strings.TrimSpace(metric), "=")
+		if !found || name == "" {
+			slog.Warn("Invalid custom metric", "value", metric)
+			continue
+		}
+		// resolve symlinks so a link in /proc or /sys can't point elsewhere
+		resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+		if err != nil {
+			slog.Warn("Invalid custom metric path", "name", name, "err", err)
+			continue
+		}
+		path = resolved
+		if !hasCustomMetricPrefix(path) {
+			slog.Warn("Custom metric path must be in /proc or /sys", "name", name, "path", path)
+			continue
+		}
+		a.customMetrics[name] = path
+	}
+	slog.Info("CUSTOM_METRICS", "metrics", a.customMetrics)
+}
+
+func hasCustomMetricPrefix(path string) bool {
+	for _, prefix := range customMetricPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the current value of each custom metric. Metrics that can't be
+// read or parsed are left out.
+func (a *Agent) getCustomMetrics() map[string]float64 {
+	values := make(map[string]float64, len(a.customMetrics))
+	for name, path := range a.customMetrics {
+		value, err := a.readCustomMetric(path)
+		if err != nil {
+			slog.Debug("Error reading custom metric", "name", name, "err", err)
+			continue
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// Reads the first number in the file at path. A path whose previous read is
+// still blocked is skipped so hung reads don't pile up.
+func (a *Agent) readCustomMetric(path string) (float64, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	if _, hung := a.customPending.LoadOrStore(path, struct{}{}); hung {
+		return 0, fmt.Errorf("previous read of %s has not finished", path)
+	}
+	// sysfs reads can block on unresponsive hardware
+	done := make(chan result, 1)
+	go func() {
+		defer a.customPending.Delete(path)
+		data, err := os.ReadFile(path)
+		done <- result{data, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return 0, r.err
+		}
+		fields := strings.Fields(string(r.data))
+		if len(fields) == 0 {
+			return 0, errors.New("empty file")
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, err
+		}
+		return twoDecimals(value), nil
+	case <-time.After(customMetricTimeout):
+		return 0, fmt.Errorf("timed out reading %s", path)
+	}
+}
@@ -2,11 +2,13 @@ package agent
 
 import (
 	"beszel/internal/entities/system"
+	"context"
 	"log/slog"
 	"time"
 
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/shirou/gopsutil/v4/disk"
@@ -14,6 +16,47 @@ import (
 
 // Sets up the filesystems to monitor for disk usage and I/O.
 func (a *Agent) initializeDiskInfo() {
+	a.fsRefreshTime = time.Now()
+	fsStats, diskIoCounters, _ := discoverFilesystems(slog.Info)
+	a.fsStats = fsStats
+	a.initializeDiskIoStats(diskIoCounters)
+}
+
+// Re-discovers the filesystems to monitor, so drives and mounts that appear
+// later (e.g. hot-plugged USB drives or NFS shares) are tracked and ones that
+// disappear are dropped. Filesystems that persist keep their previous stats.
+func (a *Agent) refreshDiskInfo() {
+	a.fsRefreshTime = time.Now()
+	// details are only logged at startup, so this doesn't repeat them
+	fsStats, diskIoCounters, err := discoverFilesystems(slog.Debug)
+	if err != nil {
+		slog.Debug("Not refreshing filesystems", "err", err)
+		return
+	}
+	for key, stats := range fsStats {
+		if prev, ok := a.fsStats[key]; ok && prev.Mountpoint == stats.Mountpoint && prev.Root == stats.Root {
+			fsStats[key] = prev
+			continue
+		}
+		slog.Info("Detected filesystem", "name", key, "mountpoint", stats.Mountpoint)
+		if !startDiskIo(key, stats, diskIoCounters) {
+			slog.Warn("Device not found in diskstats", "name", key)
+		}
+	}
+	for key, stats := range a.fsStats {
+		if _, ok := fsStats[key]; !ok {
+			slog.Info("Filesystem removed", "name", key, "mountpoint", stats.Mountpoint)
+		}
+	}
+	a.fsStats = fsStats
+	a.updateFsNames()
+}
+
+// Returns the filesystems to monitor and the current diskstats. Informational
+// messages are logged with info. err is set if partitions or diskstats couldn't
+// be read, in which case the result may be incomplete.
+func discoverFilesystems(info func(msg string, args ...any)) (fsStats map[string]*system.FsStats, diskIoCounters map[string]disk.IOCountersStat, err error) {
+	fsStats = make(map[string]*system.FsStats)
 	filesystem := os.Getenv("FILESYSTEM")
 	efPath := "/extra-filesystems"
 	hasRoot := false
@@ -24,29 +67,53 @@ func (a *Agent) initializeDiskInfo() {
 	}
 	slog.Debug("Disk", "partitions", partitions)
 
+	// filesystem type of each mount
+	fstypes := make(map[string]string, len(partitions))
+	for _, p := range partitions {
+		fstypes[p.Mountpoint] = p.Fstype
+	}
+
+	// mountpoints and filesystem types to skip (never applies to the root filesystem)
+	excluded := make(map[string]struct{})
+	if exclude, exists := os.LookupEnv("EXCLUDE_FS"); exists {
+		for _, value := range strings.Split(exclude, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				if strings.HasPrefix(value, "/") {
+					value = filepath.Clean(value)
+				}
+				excluded[value] = struct{}{}
+			}
+		}
+	}
+
 	// ioContext := context.WithValue(a.sensorsContext,
 	// 	common.EnvKey, common.EnvMap{common.HostProcEnvKey: "/tmp/testproc"},
 	// )
 	// diskIoCounters, err := disk.IOCountersWithContext(ioContext)
 
-	diskIoCounters, err := disk.IOCounters()
-	if err != nil {
-		slog.Error("Error getting diskstats", "err", err)
+	diskIoCounters, ioErr := disk.IOCounters()
+	if ioErr != nil {
+		slog.Error("Error getting diskstats", "err", ioErr)
+		err = ioErr
 	}
 	slog.Debug("Disk I/O", "diskstats", diskIoCounters)
 
 	// Helper function to add a filesystem to fsStats if it doesn't exist
 	addFsStat := func(device, mountpoint string, root bool) {
+		if !root && isExcludedFs(excluded, mountpoint, fstypes[mountpoint]) {
+			info("Excluding filesystem", "mountpoint", mountpoint)
+			return
+		}
 		key := filepath.Base(device)
 		var ioMatch bool
-		if _, exists := a.fsStats[key]; !exists {
+		if _, exists := fsStats[key]; !exists {
 			if root {
-				slog.Info("Detected root device", "name", key)
+				info("Detected root device", "name", key)
 				// Check if root device is in /proc/diskstats, use fallback if not
 				if _, ioMatch = diskIoCounters[key]; !ioMatch {
-					key, ioMatch = findIoDevice(filesystem, diskIoCounters, a.fsStats)
+					key, ioMatch = findIoDevice(filesystem, diskIoCounters, fsStats)
 					if !ioMatch {
-						slog.Info("Using I/O fallback", "device", device, "mountpoint", mountpoint, "fallback", key)
+						info("Using I/O fallback", "device", device, "mountpoint", mountpoint, "fallback", key)
 					}
 				}
 			} else {
@@ -60,7 +127,7 @@ func (a *Agent) initializeDiskInfo() {
 					}
 				}
 			}
-			a.fsStats[key] = &system.FsStats{Root: root, Mountpoint: mountpoint}
+			fsStats[key] = &system.FsStats{Root: root, Mountpoint: mountpoint}
 		}
 	}
 
@@ -105,7 +172,7 @@ func (a *Agent) initializeDiskInfo() {
 		// fmt.Println(p.Device, p.Mountpoint)
 		// Binary root fallback or docker root fallback
 		if !hasRoot && (p.Mountpoint == "/" || (p.Mountpoint == "/etc/hosts" && strings.HasPrefix(p.Device, "/dev"))) {
-			fs, match := findIoDevice(filepath.Base(p.Device), diskIoCounters, a.fsStats)
+			fs, match := findIoDevice(filepath.Base(p.Device), diskIoCounters, fsStats)
 			if match {
 				addFsStat(fs, p.Mountpoint, true)
 				hasRoot = true
@@ -121,7 +188,7 @@ func (a *Agent) initializeDiskInfo() {
 	// Check all folders in /extra-filesystems and add them if not already present
 	if folders, err := os.ReadDir(efPath); err == nil {
 		existingMountpoints := make(map[string]bool)
-		for _, stats := range a.fsStats {
+		for _, stats := range fsStats {
 			existingMountpoints[stats.Mountpoint] = true
 		}
 		for _, folder := range folders {
@@ -137,12 +204,26 @@ func (a *Agent) initializeDiskInfo() {
 
 	// If no root filesystem set, use fallback
 	if !hasRoot {
-		rootDevice, _ := findIoDevice(filepath.Base(filesystem), diskIoCounters, a.fsStats)
-		slog.Info("Root disk", "mountpoint", "/", "io", rootDevice)
-		a.fsStats[rootDevice] = &system.FsStats{Root: true, Mountpoint: "/"}
+		rootDevice, _ := findIoDevice(filepath.Base(filesystem), diskIoCounters, fsStats)
+		info("Root disk", "mountpoint", "/", "io", rootDevice)
+		fsStats[rootDevice] = &system.FsStats{Root: true, Mountpoint: "/"}
 	}
 
-	a.initializeDiskIoStats(diskIoCounters)
+	// set once so the type survives failed usage queries
+	for _, stats := range fsStats {
+		stats.Fstype = fstypes[stats.Mountpoint]
+	}
+
+	return fsStats, diskIoCounters, err
+}
+
+// Returns true if the mountpoint or filesystem type is listed in EXCLUDE_FS
+func isExcludedFs(excluded map[string]struct{}, mountpoint, fstype string) bool {
+	if _, ok := excluded[filepath.Clean(mountpoint)]; ok {
+		return true
+	}
+	_, ok := excluded[fstype]
+	return fstype != "" && ok
 }
 
 // Returns matching device from /proc/diskstats,
@@ -169,17 +250,221 @@ func findIoDevice(filesystem string, diskIoCounters map[string]disk.IOCountersSt
 // Sets start values for disk I/O stats.
 func (a *Agent) initializeDiskIoStats(diskIoCounters map[string]disk.IOCountersStat) {
 	for device, stats := range a.fsStats {
-		// skip if not in diskIoCounters
-		d, exists := diskIoCounters[device]
-		if !exists {
+		if !startDiskIo(device, stats, diskIoCounters) {
 			slog.Warn("Device not found in diskstats", "name", device)
+		}
+	}
+	a.updateFsNames()
+}
+
+// Sets the initial I/O counters of a filesystem's device. Returns false if the
+// device isn't in diskstats.
+func startDiskIo(device string, stats *system.FsStats, diskIoCounters map[string]disk.IOCountersStat) bool {
+	d, exists := diskIoCounters[device]
+	if !exists {
+		return false
+	}
+	stats.Time = time.Now()
+	stats.TotalRead = d.ReadBytes
+	stats.TotalWrite = d.WriteBytes
+	stats.TotalIoTime = d.IoTime
+	stats.TotalReadOps = d.ReadCount
+	stats.TotalWriteOps = d.WriteCount
+	stats.TotalOpTime = d.ReadTime + d.WriteTime
+	stats.DiskType, stats.Transport = getDiskType(device)
+	return true
+}
+
+// Sets the list of valid io device names to the filesystems with I/O counters
+func (a *Agent) updateFsNames() {
+	a.fsNames = a.fsNames[:0]
+	for device, stats := range a.fsStats {
+		if !stats.Time.IsZero() {
+			a.fsNames = append(a.fsNames, device)
+		}
+	}
+}
+
+// Updates disk usage for each filesystem. If DISK_USAGE_TIMEOUT is set, mounts are
+// queried concurrently and a mount that doesn't respond in time (e.g. hung NFS share)
+// keeps its last value and is flagged instead of stalling the whole collection.
+func (a *Agent) updateDiskUsage(systemStats *system.Stats) {
+	if a.diskUsageTimeout == 0 {
+		for _, stats := range a.fsStats {
+			d, err := disk.Usage(stats.Mountpoint)
+			a.setDiskUsage(systemStats, stats, d, err)
+		}
+		return
+	}
+
+	type usageResult struct {
+		stats *system.FsStats
+		usage *disk.UsageStat
+		err   error
+	}
+	results := make(chan usageResult, len(a.fsStats))
+	// a closed Done channel is seen by the collector and every worker
+	ctx, cancel := context.WithTimeout(context.Background(), a.diskUsageTimeout)
+	defer cancel()
+	waiting := make(map[*system.FsStats]struct{}, len(a.fsStats))
+
+	for _, stats := range a.fsStats {
+		// don't query again while a previous call for this mount is still hung
+		if _, hung := a.diskUsagePending.LoadOrStore(stats.Mountpoint, struct{}{}); hung {
+			a.setDiskUsageTimedOut(systemStats, stats)
 			continue
 		}
-		// populate initial values
-		stats.Time = time.Now()
-		stats.TotalRead = d.ReadBytes
-		stats.TotalWrite = d.WriteBytes
-		// add to list of valid io device names
-		a.fsNames = append(a.fsNames, device)
+		waiting[stats] = struct{}{}
+		go func() {
+			// limit the number of concurrent statfs calls
+			select {
+			case a.diskUsageSem <- struct{}{}:
+			case <-ctx.Done():
+				a.diskUsagePending.Delete(stats.Mountpoint)
+				return
+			}
+			done := make(chan usageResult, 1)
+			go func() {
+				// cleared when the call returns, so a hung mount isn't queried again
+				defer a.diskUsagePending.Delete(stats.Mountpoint)
+				d, err := disk.Usage(stats.Mountpoint)
+				done <- usageResult{stats, d, err}
+			}()
+			// the slot is freed at the deadline even if the call is hung,
+			// so hung mounts can't starve the others
+			select {
+			case res := <-done:
+				<-a.diskUsageSem
+				results <- res
+			case <-ctx.Done():
+				<-a.diskUsageSem
+			}
+		}()
+	}
+
+	for len(waiting) > 0 {
+		select {
+		case res := <-results:
+			delete(waiting, res.stats)
+			a.setDiskUsage(systemStats, res.stats, res.usage, res.err)
+		case <-ctx.Done():
+			for stats := range waiting {
+				slog.Warn("Disk usage timed out", "name", stats.Mountpoint)
+				a.setDiskUsageTimedOut(systemStats, stats)
+			}
+			return
+		}
+	}
+}
+
+// Applies the result of disk.Usage to the filesystem and root disk stats
+func (a *Agent) setDiskUsage(systemStats *system.Stats, stats *system.FsStats, d *disk.UsageStat, err error) {
+	stats.TimedOut = false
+	if err != nil {
+		// reset stats if error (likely unmounted)
+		slog.Error("Error getting disk stats", "name", stats.Mountpoint, "err", err)
+		stats.DiskTotal = 0
+		stats.DiskUsed = 0
+		stats.InodesTotal = 0
+		stats.InodesUsed = 0
+		stats.InodesPct = 0
+		stats.TotalRead = 0
+		stats.TotalWrite = 0
+		return
+	}
+	stats.DiskTotal = bytesToGigabytes(d.Total)
+	stats.DiskUsed = bytesToGigabytes(d.Used)
+	// zero on filesystems without inodes (windows, btrfs, some network mounts)
+	stats.InodesTotal = d.InodesTotal
+	stats.InodesUsed = d.InodesUsed
+	stats.InodesPct = twoDecimals(d.InodesUsedPercent)
+	if stats.Root {
+		systemStats.DiskTotal = bytesToGigabytes(d.Total)
+		systemStats.DiskUsed = bytesToGigabytes(d.Used)
+		systemStats.DiskPct = twoDecimals(d.UsedPercent)
+		systemStats.InodesPct = stats.InodesPct
+	}
+}
+
+// Flags a filesystem whose usage query timed out and keeps its last values
+func (a *Agent) setDiskUsageTimedOut(systemStats *system.Stats, stats *system.FsStats) {
+	stats.TimedOut = true
+	if stats.Root && stats.DiskTotal > 0 {
+		systemStats.DiskTotal = stats.DiskTotal
+		systemStats.DiskUsed = stats.DiskUsed
+		systemStats.DiskPct = twoDecimals(stats.DiskUsed / stats.DiskTotal * 100)
+		systemStats.InodesPct = stats.InodesPct
+	}
+}
+
+// Returns the percentage of time a device had I/O in flight (like iostat %util)
+// given the increase in io_ticks (ms) over the elapsed seconds. Capped at 100
+// since the counter and elapsed time aren't sampled at exactly the same moment.
+func diskUtilization(ioTimeDelta uint64, secondsElapsed float64) float64 {
+	if secondsElapsed <= 0 {
+		return 0
+	}
+	return twoDecimals(min(float64(ioTimeDelta)/(secondsElapsed*1000)*100, 100))
+}
+
+// Returns the average time (ms) each operation took to complete, including
+// time spent queued (like iostat await), or 0 if there were no operations
+func diskAwait(opTimeDelta, opsDelta uint64) float64 {
+	if opsDelta == 0 {
+		return 0
+	}
+	return twoDecimals(float64(opTimeDelta) / float64(opsDelta))
+}
+
+// Returns the number of errors ext4 has recorded for the device since it was
+// mounted. bool is false if the filesystem doesn't expose a count (xfs and
+// others only log errors to the kernel ring buffer).
+func readFsErrors(device string) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join("/sys/fs/ext4", device, "errors_count"))
+	if err != nil {
+		return 0, false
+	}
+	count, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return count, err == nil
+}
+
+// Returns whether the disk holding the block device is an "ssd" or "hdd" and
+// how it's attached, from sysfs. Values are empty if they can't be determined.
+func getDiskType(device string) (diskType, transport string) {
+	disk := parentDisk(device)
+	if data, err := os.ReadFile(filepath.Join("/sys/block", disk, "queue/rotational")); err == nil {
+		switch strings.TrimSpace(string(data)) {
+		case "0":
+			diskType = "ssd"
+		case "1":
+			diskType = "hdd"
+		}
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join("/sys/block", disk))
+	if err != nil {
+		return diskType, ""
+	}
+	switch {
+	case strings.HasPrefix(disk, "nvme"):
+		transport = "nvme"
+	case strings.Contains(path, "/usb"):
+		transport = "usb"
+	case strings.Contains(path, "/virtio"):
+		transport = "virtio"
+	case strings.Contains(path, "/ata"):
+		transport = "sata"
+	case strings.Contains(path, "/mmc"):
+		transport = "mmc"
+	}
+	return diskType, transport
+}
+
+// Resolves a partition to the disk it's on. Other devices are returned as is.
+func parentDisk(device string) string {
+	if path, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", device)); err == nil {
+		if _, err := os.Stat(filepath.Join(path, "partition")); err == nil {
+			return filepath.Base(filepath.Dir(path))
+		}
 	}
+	return device
 }
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	diskLatencyWindow   = 10 * time.Second // How long each sample traces block I/O
+	diskLatencyInterval = time.Minute      // Time between the start of each sample
+)
+
+// bpftrace program that records a histogram of block request latency (µs)
+// for each device, keyed by the kernel's dev_t (major << 20 | minor)
+var diskLatencyScript = fmt.Sprintf(`tracepoint:block:block_rq_issue { @start[args.dev, args.sector] = nsecs; }
+tracepoint:block:block_rq_complete /@start[args.dev, args.sector]/ {
+	@us[args.dev] = hist((nsecs - @start[args.dev, args.sector]) / 1000);
+	delete(@start[args.dev, args.sector]);
+}
+interval:s:%d { exit(); }
+END { clear(@start); }`, int(diskLatencyWindow.Seconds()))
+
+// Samples block I/O with bpftrace to report p99 latency per disk
+type diskLatencyManager struct {
+	p99   map[string]float64 // disk name -> p99 latency (ms) of the last sample
+	mutex sync.Mutex
+}
+
+// Returns a new diskLatencyManager and starts sampling in the background,
+// or nil if bpftrace is not installed
+func newDiskLatencyManager(ctx context.Context) *diskLatencyManager {
+	if _, err := exec.LookPath("bpftrace"); err != nil {
+		slog.Warn("DISK_LATENCY requires bpftrace", "err", err)
+		return nil
+	}
+	dm := &diskLatencyManager{}
+	go func() {
+		for {
+			start := time.Now()
+			p99, err := sampleDiskLatency(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				// usually missing privileges or kernel support, which won't fix itself
+				slog.Warn("Disabling disk latency sampling", "err", err)
+				return
+			}
+			dm.mutex.Lock()
+			dm.p99 = p99
+			dm.mutex.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(diskLatencyInterval - time.Since(start)):
+			}
+		}
+	}()
+	return dm
+}
+
+// Returns the p99 latency (ms) of the disk backing device, or 0 if the disk
+// had no I/O during the last sample
+func (dm *diskLatencyManager) getP99(device string) float64 {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	return dm.p99[parentDisk(device)]
+}
+
+// bpftrace json histogram bucket
+type bpftraceBucket struct {
+	Min   *uint64 `json:"min"`
+	Max   *uint64 `json:"max"`
+	Count uint64  `json:"count"`
+}
+
+// Runs the bpftrace program for one window and returns p99 latency per disk
+func sampleDiskLatency(ctx context.Context) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, diskLatencyWindow+30*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "bpftrace", "-f", "json", "-e", diskLatencyScript).Output()
+	if err != nil {
+		return nil, err
+	}
+	p99 := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Type string                                 `json:"type"`
+			Data map[string]map[string][]bpftraceBucket `json:"data"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil || line.Type != "hist" {
+			continue
+		}
+		for dev, buckets := range line.Data["@us"] {
+			name := blockDeviceName(dev)
+			if name == "" {
+				continue
+			}
+			p99[name] = twoDecimals(float64(histogramPercentile(buckets, 0.99)) / 1000)
+		}
+	}
+	return p99, nil
+}
+
+// Returns the upper bound of the bucket containing the given percentile
+func histogramPercentile(buckets []bpftraceBucket, percentile float64) uint64 {
+	var total uint64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	target := uint64(float64(total) * percentile)
+	var seen uint64
+	for _, b := range buckets {
+		seen += b.Count
+		if seen >= target && b.Count > 0 {
+			if b.Max != nil {
+				return *b.Max
+			}
+			// last bucket is unbounded, so use its lower bound
+			if b.Min != nil {
+				return *b.Min
+			}
+		}
+	}
+	return 0
+}
+
+// Returns the name of the block device with the kernel dev_t number, e.g. "sda"
+func blockDeviceName(dev string) string {
+	devt, err := strconv.ParseUint(dev, 10, 64)
+	if err != nil {
+		return ""
+	}
+	uevent, err := os.ReadFile(fmt.Sprintf("/sys/dev/block/%d:%d/uevent", devt>>20, devt&0xfffff))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(uevent), "\n") {
+		if name, found := strings.CutPrefix(line, "DEVNAME="); found {
+			return filepath.Base(name)
+		}
+	}
+	return ""
+}
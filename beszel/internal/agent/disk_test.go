@@ -0,0 +1,27 @@
+package agent
+
+import "testing"
+
+func TestDiskUtilization(t *testing.T) {
+	tests := []struct {
+		name           string
+		ioTimeDelta    uint64
+		secondsElapsed float64
+		want           float64
+	}{
+		{"idle", 0, 60, 0},
+		{"half busy", 30_000, 60, 50},
+		{"fully busy", 60_000, 60, 100},
+		{"rounded", 1, 3, 0.03},
+		{"capped at 100", 61_000, 60, 100},
+		{"no time elapsed", 1000, 0, 0},
+		{"negative time elapsed", 1000, -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diskUtilization(tt.ioTimeDelta, tt.secondsElapsed); got != tt.want {
+				t.Errorf("diskUtilization(%d, %v) = %v; want %v", tt.ioTimeDelta, tt.secondsElapsed, got, tt.want)
+			}
+		})
+	}
+}
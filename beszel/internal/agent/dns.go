@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	dnsProbeTimeout         = 5 * time.Second
+	dnsProbeDefaultInterval = time.Minute
+)
+
+type dnsProbe struct {
+	host    string
+	status  system.DnsStatus
+	updated time.Time // Time of the last probe
+	mutex   sync.Mutex
+}
+
+// Returns a new dnsProbe for host and starts probing in the background
+func newDnsProbe(ctx context.Context, host string) *dnsProbe {
+	interval := dnsProbeDefaultInterval
+	if val, exists := os.LookupEnv("DNS_PROBE_INTERVAL"); exists {
+		if d, err := time.ParseDuration(val); err == nil && d >= time.Second {
+			interval = d
+		} else {
+			slog.Warn("Invalid DNS_PROBE_INTERVAL", "value", val)
+		}
+	}
+	slog.Info("DNS_PROBE", "host", host, "interval", interval)
+	dp := &dnsProbe{host: host}
+	dp.probe()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			dp.probe()
+		}
+	}()
+	return dp
+}
+
+// Resolves the probe host and records the result
+func (dp *dnsProbe) probe() {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err := net.DefaultResolver.LookupHost(ctx, dp.host)
+	status := system.DnsStatus{
+		Ok:      err == nil,
+		Latency: twoDecimals(float64(time.Since(start).Microseconds()) / 1000),
+	}
+	if err != nil {
+		slog.Debug("DNS probe failed", "host", dp.host, "err", err)
+		status.Error = err.Error()
+	}
+	dp.mutex.Lock()
+	dp.status = status
+	dp.updated = time.Now()
+	dp.mutex.Unlock()
+}
+
+// Returns the time of the last probe
+func (dp *dnsProbe) lastUpdate() time.Time {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	return dp.updated
+}
+
+// Returns a copy of the latest probe result
+func (dp *dnsProbe) getStatus() *system.DnsStatus {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+	status := dp.status
+	return &status
+}
@@ -2,23 +2,31 @@ package agent
 
 import (
 	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/blang/semver"
+	"github.com/shirou/gopsutil/v4/mem"
 )
 
+// Upper bound for DOCKER_CONCURRENCY, beyond which the daemon is the bottleneck
+const maxDockerConcurrency = 100
+
 type dockerManager struct {
 	client              *http.Client                // Client to query Docker API
+	timeout             time.Duration               // Deadline for each Docker API request
 	wg                  sync.WaitGroup              // WaitGroup to wait for all goroutines to finish
 	sem                 chan struct{}               // Semaphore to limit concurrent container requests
 	containerStatsMutex sync.RWMutex                // Mutex to prevent concurrent access to containerStatsMap
@@ -26,6 +34,25 @@ type dockerManager struct {
 	containerStatsMap   map[string]*container.Stats // Keeps track of container stats
 	validIds            map[string]struct{}         // Map of valid container ids, used to prune invalid containers from containerStatsMap
 	goodDockerVersion   bool                        // Whether docker version is at least 25.0.0 (one-shot works correctly)
+	containerStates     system.ContainerStates      // Number of containers in each state
+	labelKeys           []string                    // Container label keys to include in stats
+	filter              *containerFilter            // Limits reported containers (nil if not set)
+	imageUpdates        *imageUpdateChecker         // Checks registries for newer images (nil if disabled)
+	inspect             inspectCache                // Cached container inspect results
+	oom                 oomTracker                  // Out of memory kills not yet reported
+	cpuConfig           bool                        // Whether to report configured cpu shares / quota
+	sockets             bool                        // Whether to report tcp socket states
+	memDetail           bool                        // Whether to report rss / cache / mapped memory
+	grouper             *replicaGrouper             // Rolls up replicas of a service (nil if disabled)
+	groups              map[string]string           // Container id -> replica group
+	diskUsage           *dockerDiskUsageManager     // Reports Docker disk usage (nil if disabled)
+	logErrors           *logErrorCounter            // Counts error lines in container logs (nil if disabled)
+	rateWindow          *rateWindow                 // Computes rates over RATE_WINDOW (nil if disabled)
+	failureThreshold    int                         // Consecutive failures before docker is reported unavailable
+	failures            int                         // Consecutive failed container list requests
+	status              system.DockerStatus         // Reported availability of the docker api
+	expected            bool                        // Whether docker should be running (DOCKER_HOST set or seen once)
+	hostMem             uint64                      // Host memory in bytes, the limit docker reports for unlimited containers
 }
 
 // Add goroutine to the queue
@@ -46,13 +73,7 @@ func (d *dockerManager) dequeue() {
 
 // Returns stats for all running containers
 func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
-	resp, err := dm.client.Get("http://localhost/containers/json")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if err := json.NewDecoder(resp.Body).Decode(&dm.apiContainerList); err != nil {
+	if err := dm.getJson("/containers/json?all=1", &dm.apiContainerList); err != nil {
 		return nil, err
 	}
 
@@ -64,17 +85,45 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 	} else {
 		clear(dm.validIds)
 	}
+	if dm.grouper != nil {
+		dm.groups = make(map[string]string)
+	}
 
 	var failedContainters []container.ApiInfo
 
+	dm.containerStates = system.ContainerStates{}
 	for _, ctr := range *dm.apiContainerList {
+		// skip filtered out containers entirely (stats of previously seen ones are pruned below)
+		if dm.filter != nil && !dm.filter.match(ctr) {
+			continue
+		}
+		// count containers by state and only get stats for running containers
+		switch ctr.State {
+		case "running":
+			dm.containerStates.Running++
+		case "paused":
+			dm.containerStates.Paused++
+			continue
+		case "restarting":
+			dm.containerStates.Restarting++
+			dm.checkStopped(ctr)
+			continue
+		default:
+			dm.containerStates.Stopped++
+			dm.checkStopped(ctr)
+			continue
+		}
 		ctr.IdShort = ctr.Id[:12]
 		dm.validIds[ctr.IdShort] = struct{}{}
+		if dm.grouper != nil {
+			dm.groups[ctr.IdShort] = dm.grouper.groupName(ctr)
+		}
 		// check if container is less than 1 minute old (possible restart)
 		// note: can't use Created field because it's not updated on restart
 		if strings.Contains(ctr.Status, "second") {
 			// if so, remove old container data
 			dm.deleteContainerStatsSync(ctr.IdShort)
+			dm.deleteInspectSync(ctr.IdShort)
 		}
 		dm.queue()
 		go func() {
@@ -84,7 +133,12 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 			if err != nil {
 				dm.containerStatsMutex.Lock()
 				delete(dm.containerStatsMap, ctr.IdShort)
-				failedContainters = append(failedContainters, ctr)
+				// a hung daemon would time out again, so report the other containers without it
+				if isTimeout(err) {
+					slog.Warn("Timed out getting container stats", "name", ctr.Names[0][1:], "timeout", dm.timeout)
+				} else {
+					failedContainters = append(failedContainters, ctr)
+				}
 				dm.containerStatsMutex.Unlock()
 			}
 		}()
@@ -99,7 +153,7 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 			dm.queue()
 			go func() {
 				defer dm.dequeue()
-				err = dm.updateContainerStats(ctr)
+				err := dm.updateContainerStats(ctr)
 				if err != nil {
 					slog.Error("Error getting container stats", "err", err)
 				}
@@ -108,6 +162,15 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 		dm.wg.Wait()
 	}
 
+	dm.pruneInspectCache()
+	dm.oom.prune(*dm.apiContainerList)
+	if dm.logErrors != nil {
+		dm.logErrors.prune(dm.validIds)
+	}
+	if dm.imageUpdates != nil {
+		dm.imageUpdates.prune(*dm.apiContainerList)
+	}
+
 	// populate final stats and remove old / invalid container stats
 	stats := make([]*container.Stats, 0, containersLength)
 	for id, v := range dm.containerStatsMap {
@@ -118,6 +181,12 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 		}
 	}
 
+	// roll up replicas into one entry per service
+	if dm.grouper != nil {
+		stats = dm.grouper.merge(dm.containerStatsMap, dm.groups)
+	}
+	// out of memory kills of containers that stopped or were removed
+	stats = append(stats, dm.oom.notRunning(dm.validIds)...)
 	return stats, nil
 }
 
@@ -125,11 +194,51 @@ func (dm *dockerManager) getDockerStats() ([]*container.Stats, error) {
 func (dm *dockerManager) updateContainerStats(ctr container.ApiInfo) error {
 	name := ctr.Names[0][1:]
 
-	resp, err := dm.client.Get("http://localhost/containers/" + ctr.IdShort + "/stats?stream=0&one-shot=1")
-	if err != nil {
+	// restart count, uptime, configured cpu constraints, and socket states (inspect is
+	// cached and dropped when the container restarts, so this rarely hits the api)
+	var cpuConfig *container.CpuConfig
+	var sockets *container.SocketCounts
+	var restarts int
+	var uptime uint64
+	var createdImage string
+	if info, err := dm.inspectContainer(ctr.IdShort); err == nil {
+		restarts = info.RestartCount
+		dm.oom.record(ctr.IdShort, name, info, false)
+		createdImage = info.Config.Image
+		// list Created isn't updated on restart, so use the inspect start time
+		if !info.State.StartedAt.IsZero() {
+			uptime = uint64(max(time.Since(info.State.StartedAt).Seconds(), 0))
+		}
+		hc := info.HostConfig
+		if dm.cpuConfig && (hc.CpuShares != 0 || hc.CpuQuota != 0 || hc.CpuPeriod != 0 || hc.NanoCpus != 0) {
+			cpuConfig = &container.CpuConfig{
+				Shares:   hc.CpuShares,
+				Quota:    hc.CpuQuota,
+				Period:   hc.CpuPeriod,
+				NanoCpus: hc.NanoCpus,
+			}
+		}
+		// host networked containers share the host's sockets, so skip them
+		if dm.sockets && hc.NetworkMode != "host" && info.State.Pid > 0 {
+			if sockets, err = readSocketCounts(info.State.Pid); err != nil {
+				slog.Debug("Error reading container sockets", "name", name, "err", err)
+			}
+		}
+	} else {
+		slog.Debug("Error inspecting container", "name", name, "err", err)
+	}
+
+	// error lines in logs since the last update
+	var logErrors float64
+	if dm.logErrors != nil {
+		logErrors, _ = dm.logErrors.errorRate(ctr.IdShort, name)
+	}
+
+	// docker host container stats response
+	var res container.ApiStats
+	if err := dm.getJson("/containers/"+ctr.IdShort+"/stats?stream=0&one-shot=1", &res); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
 	dm.containerStatsMutex.Lock()
 	defer dm.containerStatsMutex.Unlock()
@@ -141,17 +250,31 @@ func (dm *dockerManager) updateContainerStats(ctr container.ApiInfo) error {
 		dm.containerStatsMap[ctr.IdShort] = stats
 	}
 
+	// copy whitelisted labels
+	stats.Labels = dm.filterLabels(ctr.Labels)
+
+	stats.Image = imageName(ctr.Image, createdImage)
+	stats.Health = containerHealth(ctr.Status)
+	stats.Restarts = restarts
+	stats.OomKills = dm.oom.peek(ctr.IdShort)
+	stats.Uptime = uptime
+	stats.CpuConfig = cpuConfig
+	stats.Sockets = sockets
+	stats.LogErrors = logErrors
+
+	// cached registry check, refreshed in the background
+	if dm.imageUpdates != nil {
+		stats.UpdateAvailable = dm.imageUpdates.updateAvailable(ctr.Image)
+	}
+
 	// reset current stats
 	stats.Cpu = 0
 	stats.Mem = 0
 	stats.NetworkSent = 0
 	stats.NetworkRecv = 0
-
-	// docker host container stats response
-	var res container.ApiStats
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return err
-	}
+	stats.DiskReadPs = 0
+	stats.DiskWritePs = 0
+	stats.SwapUsed = 0
 
 	// check if container has valid data, otherwise may be in restart loop (#103)
 	if res.MemoryStats.Usage == 0 {
@@ -165,10 +288,24 @@ func (dm *dockerManager) updateContainerStats(ctr container.ApiInfo) error {
 	}
 	usedMemory := res.MemoryStats.Usage - memCache
 
-	// cpu
-	cpuDelta := res.CPUStats.CPUUsage.TotalUsage - stats.PrevCpu[0]
-	systemDelta := res.CPUStats.SystemUsage - stats.PrevCpu[1]
-	cpuPct := float64(cpuDelta) / float64(systemDelta) * 100
+	// swap (cgroup v1 includes it in stats, v2 only exposes it in the cgroup filesystem)
+	swap := res.MemoryStats.Stats.Swap
+	if swap == 0 {
+		if dir := containerCgroupDir(ctr.Id); dir != "" {
+			swap, _ = readCgroupValue(dir, "memory.swap.current")
+		}
+	}
+
+	// cpu (counters reset if the docker daemon restarts, so skip this cycle and re-baseline)
+	var cpuPct float64
+	cpuDelta, cpuOk := counterDelta(stats.PrevCpu[0], res.CPUStats.CPUUsage.TotalUsage)
+	systemDelta, systemOk := counterDelta(stats.PrevCpu[1], res.CPUStats.SystemUsage)
+	if deltas, _, ok := dm.rateWindow.deltas("container.cpu."+ctr.IdShort, res.CPUStats.CPUUsage.TotalUsage, res.CPUStats.SystemUsage); ok {
+		cpuDelta, systemDelta = deltas[0], deltas[1]
+	}
+	if cpuOk && systemOk && systemDelta > 0 {
+		cpuPct = float64(cpuDelta) / float64(systemDelta) * 100
+	}
 	if cpuPct > 100 {
 		return fmt.Errorf("%s cpu pct greater than 100: %+v", name, cpuPct)
 	}
@@ -184,21 +321,184 @@ func (dm *dockerManager) updateContainerStats(ctr container.ApiInfo) error {
 	// prevent first run from sending all prev sent/recv bytes
 	if initialized {
 		secondsElapsed := time.Since(stats.PrevNet.Time).Seconds()
-		sent_delta = float64(total_sent-stats.PrevNet.Sent) / secondsElapsed
-		recv_delta = float64(total_recv-stats.PrevNet.Recv) / secondsElapsed
+		sent, sentOk := counterDelta(stats.PrevNet.Sent, total_sent)
+		recv, recvOk := counterDelta(stats.PrevNet.Recv, total_recv)
+		// leave at zero if either counter was reset
+		if deltas, seconds, ok := dm.rateWindow.deltas("container.net."+ctr.IdShort, total_sent, total_recv); ok {
+			sent, recv, secondsElapsed = deltas[0], deltas[1], seconds
+		}
+		if sentOk && recvOk {
+			sent_delta = float64(sent) / secondsElapsed
+			recv_delta = float64(recv) / secondsElapsed
+		}
 	}
 	stats.PrevNet.Sent = total_sent
 	stats.PrevNet.Recv = total_recv
 	stats.PrevNet.Time = time.Now()
 
-	stats.Cpu = twoDecimals(cpuPct)
+	// block I/O (left at zero if blkio stats are empty)
+	var totalRead, totalWrite uint64
+	for _, entry := range res.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			totalRead += entry.Value
+		case "write":
+			totalWrite += entry.Value
+		}
+	}
+	var readPs, writePs float64
+	if initialized {
+		secondsElapsed := time.Since(stats.PrevDisk.Time).Seconds()
+		read, readOk := counterDelta(stats.PrevDisk.Read, totalRead)
+		write, writeOk := counterDelta(stats.PrevDisk.Write, totalWrite)
+		if deltas, seconds, ok := dm.rateWindow.deltas("container.disk."+ctr.IdShort, totalRead, totalWrite); ok {
+			read, write, secondsElapsed = deltas[0], deltas[1], seconds
+		}
+		if readOk && writeOk && secondsElapsed > 0 {
+			readPs = float64(read) / secondsElapsed
+			writePs = float64(write) / secondsElapsed
+		}
+	}
+	stats.PrevDisk.Read = totalRead
+	stats.PrevDisk.Write = totalWrite
+	stats.PrevDisk.Time = time.Now()
+
+	stats.Cpu = smallDecimals(cpuPct)
 	stats.Mem = bytesToMegabytes(float64(usedMemory))
-	stats.NetworkSent = bytesToMegabytes(sent_delta)
-	stats.NetworkRecv = bytesToMegabytes(recv_delta)
+	// docker reports the host total (or more, on cgroup v1) when no limit is set
+	stats.MemLimit, stats.MemPct = 0, 0
+	if limit := res.MemoryStats.Limit; limit > 0 && (dm.hostMem == 0 || limit < dm.hostMem) {
+		stats.MemLimit = bytesToMegabytes(float64(limit))
+		stats.MemPct = twoDecimals(float64(usedMemory) / float64(limit) * 100)
+	}
+	stats.SwapUsed = bytesToMegabytes(float64(swap))
+	stats.MemDetail = nil
+	if dm.memDetail {
+		stats.MemDetail = getMemDetail(&res.MemoryStats.Stats)
+	}
+	stats.NetworkSent = smallMegabytesPerSecond(sent_delta)
+	stats.NetworkRecv = smallMegabytesPerSecond(recv_delta)
+	stats.DiskReadPs = smallMegabytesPerSecond(readPs)
+	stats.DiskWritePs = smallMegabytesPerSecond(writePs)
 
 	return nil
 }
 
+// Requests a Docker API path and decodes the JSON response into v. The request,
+// including reading the body, is cancelled after the timeout so a hung daemon
+// can't stall the whole update. Responses other than 2xx are returned as errors.
+func (dm *dockerManager) getJson(path string, v any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dm.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := dm.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Returns whether the error is from a request that ran out of time
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// Returns a readable image name, dropping any digest. The list reports an image id
+// if the tag was removed or moved to a newer image, so fall back to the image the
+// container was created from, then to the short id.
+func imageName(image, createdImage string) string {
+	if strings.HasPrefix(image, "sha256:") && createdImage != "" {
+		image = createdImage
+	}
+	if name, _, found := strings.Cut(image, "@"); found && name != "" {
+		return name
+	}
+	if id, found := strings.CutPrefix(image, "sha256:"); found {
+		return id[:min(len(id), 12)]
+	}
+	return image
+}
+
+// Returns the healthcheck state from a container list status such as
+// "Up 2 hours (healthy)" or "Up 5 seconds (health: starting)", or an empty
+// string if the container has no healthcheck
+func containerHealth(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(healthy)"):
+		return "healthy"
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return "unhealthy"
+	case strings.HasSuffix(status, "(health: starting)"):
+		return "starting"
+	}
+	return ""
+}
+
+// Returns the rss / cache / mapped breakdown from memory.stat, using the cgroup v1
+// keys (rss, cache, mapped_file) or the v2 keys (anon, file, file_mapped).
+// Returns nil if neither set is present.
+func getMemDetail(s *container.MemoryStatsStats) *container.MemDetail {
+	rss, cache, mapped := s.Rss, s.Cache, s.MappedFile
+	if rss == 0 && cache == 0 {
+		rss, cache, mapped = s.Anon, s.File, s.FileMapped
+	}
+	if rss == 0 && cache == 0 {
+		return nil
+	}
+	return &container.MemDetail{
+		Rss:    bytesToMegabytes(float64(rss)),
+		Cache:  bytesToMegabytes(float64(cache)),
+		Mapped: bytesToMegabytes(float64(mapped)),
+	}
+}
+
+// Updates the reported docker availability after a stats request. Docker is
+// only reported unavailable after failureThreshold consecutive failures.
+func (dm *dockerManager) recordResult(err error) {
+	if err == nil {
+		dm.failures = 0
+		dm.expected = true
+		dm.status = system.DockerStatus{Available: true}
+		return
+	}
+	dm.failures++
+	dm.status.Error = err.Error()
+	if dm.failures >= dm.failureThreshold {
+		dm.status.Available = false
+	}
+}
+
+// Returns the docker availability, or nil on hosts that don't appear to use docker
+func (dm *dockerManager) getStatus() *system.DockerStatus {
+	if !dm.expected {
+		return nil
+	}
+	status := dm.status
+	return &status
+}
+
+// Returns the container labels matching labelKeys, or nil if none match
+func (dm *dockerManager) filterLabels(labels map[string]string) map[string]string {
+	var filtered map[string]string
+	for _, key := range dm.labelKeys {
+		if value, ok := labels[key]; ok {
+			if filtered == nil {
+				filtered = make(map[string]string, len(dm.labelKeys))
+			}
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
 // Delete container stats from map using mutex
 func (dm *dockerManager) deleteContainerStatsSync(id string) {
 	dm.containerStatsMutex.Lock()
@@ -206,13 +506,14 @@ func (dm *dockerManager) deleteContainerStatsSync(id string) {
 	delete(dm.containerStatsMap, id)
 }
 
-// Creates a new http client for Docker or Podman API
-func newDockerManager(a *Agent) *dockerManager {
+// Creates a new http client for Docker or Podman API. runtimeName is the
+// CONTAINER_RUNTIME, which limits the sockets tried to podman's if set to podman.
+func newDockerManager(a *Agent, runtimeName string) *dockerManager {
 	dockerHost, exists := os.LookupEnv("DOCKER_HOST")
 	if exists {
 		slog.Info("DOCKER_HOST", "host", dockerHost)
 	} else {
-		dockerHost = getDockerHost()
+		dockerHost = getDockerHost(runtimeName == "podman")
 	}
 
 	parsedURL, err := url.Parse(dockerHost)
@@ -220,6 +521,10 @@ func newDockerManager(a *Agent) *dockerManager {
 		slog.Error("Error parsing DOCKER_HOST", "err", err)
 		os.Exit(1)
 	}
+	// allow a bare socket path (e.g. $XDG_RUNTIME_DIR/podman/podman.sock)
+	if parsedURL.Scheme == "" && strings.HasPrefix(parsedURL.Path, "/") {
+		parsedURL.Scheme = "unix"
+	}
 
 	transport := &http.Transport{
 		DisableCompression: true,
@@ -251,15 +556,52 @@ func newDockerManager(a *Agent) *dockerManager {
 		slog.Info("DOCKER_TIMEOUT", "timeout", timeout)
 	}
 
+	// concurrent container requests (newer docker versions only)
+	concurrency := 5
+	if val, exists := os.LookupEnv("DOCKER_CONCURRENCY"); exists {
+		if n, err := strconv.Atoi(val); err == nil && n >= 1 && n <= maxDockerConcurrency {
+			concurrency = n
+		} else {
+			slog.Warn("Invalid DOCKER_CONCURRENCY", "value", val, "min", 1, "max", maxDockerConcurrency)
+		}
+	}
+
 	dockerClient := &dockerManager{
 		client: &http.Client{
 			Timeout:   timeout,
 			Transport: transport,
 		},
+		timeout:           timeout,
 		containerStatsMap: make(map[string]*container.Stats),
-		sem:               make(chan struct{}, 5),
+		sem:               make(chan struct{}, concurrency),
+		failureThreshold:  3,
+		status:            system.DockerStatus{Available: true},
+		expected:          exists,
 	}
 
+	if v, err := mem.VirtualMemory(); err == nil {
+		dockerClient.hostMem = v.Total
+	}
+
+	if val, exists := os.LookupEnv("DOCKER_FAILURE_THRESHOLD"); exists {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			dockerClient.failureThreshold = n
+		} else {
+			slog.Warn("Invalid DOCKER_FAILURE_THRESHOLD", "value", val)
+		}
+	}
+
+	// container label keys to pass through to the hub
+	if labels, exists := os.LookupEnv("CONTAINER_LABELS"); exists {
+		for _, key := range strings.Split(labels, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				dockerClient.labelKeys = append(dockerClient.labelKeys, key)
+			}
+		}
+	}
+
+	dockerClient.filter = newContainerFilter()
+
 	// If using podman, return client
 	if strings.Contains(dockerHost, "podman") {
 		a.systemInfo.Podman = true
@@ -270,15 +612,22 @@ func newDockerManager(a *Agent) *dockerManager {
 	// Check docker version
 	// (versions before 25.0.0 have a bug with one-shot which requires all requests to be made in one batch)
 	var versionInfo struct {
-		Version string `json:"Version"`
+		Version    string `json:"Version"`
+		Components []struct {
+			Name string `json:"Name"`
+		} `json:"Components"`
 	}
-	resp, err := dockerClient.client.Get("http://localhost/version")
-	if err != nil {
+	if err := dockerClient.getJson("/version", &versionInfo); err != nil {
 		return dockerClient
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&versionInfo); err != nil {
-		return dockerClient
+	// podman behind a socket path that doesn't mention podman reports itself as a component
+	for _, component := range versionInfo.Components {
+		if strings.HasPrefix(component.Name, "Podman") {
+			a.systemInfo.Podman = true
+			dockerClient.goodDockerVersion = true
+			return dockerClient
+		}
 	}
 
 	// if version > 24, one-shot works correctly and we can limit concurrent operations
@@ -291,10 +640,18 @@ func newDockerManager(a *Agent) *dockerManager {
 	return dockerClient
 }
 
-// Test docker / podman sockets and return if one exists
-func getDockerHost() string {
+// Test docker / podman sockets and return if one exists. Only podman's
+// sockets are tried if podmanOnly is true.
+func getDockerHost(podmanOnly bool) string {
 	scheme := "unix://"
-	socks := []string{"/var/run/docker.sock", fmt.Sprintf("/run/user/%v/podman/podman.sock", os.Getuid())}
+	runtimeDir, exists := os.LookupEnv("XDG_RUNTIME_DIR")
+	if !exists || runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%v", os.Getuid())
+	}
+	socks := []string{"/var/run/docker.sock", runtimeDir + "/podman/podman.sock", "/run/podman/podman.sock"}
+	if podmanOnly {
+		socks = socks[1:]
+	}
 	for _, sock := range socks {
 		if _, err := os.Stat(sock); err == nil {
 			return scheme + sock
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// How often to query /system/df, which walks every layer and volume
+	dockerDiskUsageInterval = 10 * time.Minute
+	// Number of volumes to report individually
+	dockerDiskUsageTopVolumes = 5
+)
+
+// Response from the Docker /system/df endpoint (only fields we use)
+type dockerApiDiskUsage struct {
+	LayersSize int64
+	Containers []struct {
+		SizeRw int64
+	}
+	Volumes []struct {
+		Name      string
+		UsageData struct {
+			Size int64 // -1 if not calculated
+		}
+	}
+	BuildCache []struct {
+		Size   int64
+		Shared bool
+	}
+}
+
+// Periodically queries Docker for its total disk usage
+type dockerDiskUsageManager struct {
+	client *http.Client
+	usage  *system.DockerDiskUsage
+	mutex  sync.Mutex
+}
+
+// Starts collecting Docker disk usage in the background using the transport of the docker client
+func newDockerDiskUsageManager(ctx context.Context, dockerClient *http.Client) *dockerDiskUsageManager {
+	dm := &dockerDiskUsageManager{
+		// /system/df can take far longer than DOCKER_TIMEOUT on hosts with many images
+		client: &http.Client{Transport: dockerClient.Transport, Timeout: 2 * time.Minute},
+	}
+	go func() {
+		for {
+			if usage, err := dm.collect(); err == nil {
+				dm.mutex.Lock()
+				dm.usage = usage
+				dm.mutex.Unlock()
+			} else {
+				slog.Warn("Error getting docker disk usage", "err", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(dockerDiskUsageInterval):
+			}
+		}
+	}()
+	return dm
+}
+
+// Returns the latest disk usage, or nil if not collected yet
+func (dm *dockerDiskUsageManager) getUsage() *system.DockerDiskUsage {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	return dm.usage
+}
+
+func (dm *dockerDiskUsageManager) collect() (*system.DockerDiskUsage, error) {
+	resp, err := dm.client.Get("http://localhost/system/df")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("system df returned %s", resp.Status)
+	}
+
+	var df dockerApiDiskUsage
+	if err := json.NewDecoder(resp.Body).Decode(&df); err != nil {
+		return nil, err
+	}
+
+	var containers, volumes, buildCache int64
+	for _, c := range df.Containers {
+		containers += c.SizeRw
+	}
+	vols := make([]system.DockerVolumeUsage, 0, len(df.Volumes))
+	for _, v := range df.Volumes {
+		if v.UsageData.Size < 0 {
+			continue
+		}
+		volumes += v.UsageData.Size
+		vols = append(vols, system.DockerVolumeUsage{Name: v.Name, Size: bytesToGigabytes(uint64(v.UsageData.Size))})
+	}
+	for _, b := range df.BuildCache {
+		if !b.Shared {
+			buildCache += b.Size
+		}
+	}
+	slices.SortFunc(vols, func(a, b system.DockerVolumeUsage) int {
+		return cmp.Compare(b.Size, a.Size)
+	})
+
+	return &system.DockerDiskUsage{
+		Images:         bytesToGigabytes(uint64(max(df.LayersSize, 0))),
+		Containers:     bytesToGigabytes(uint64(containers)),
+		Volumes:        bytesToGigabytes(uint64(volumes)),
+		BuildCache:     bytesToGigabytes(uint64(buildCache)),
+		LargestVolumes: vols[:min(len(vols), dockerDiskUsageTopVolumes)],
+	}, nil
+}
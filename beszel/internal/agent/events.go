@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Maximum number of undelivered events to keep
+const maxPendingEvents = 100
+
+// Tracks the state of event-like fields and records an event whenever one
+// changes, so the hub doesn't have to diff snapshots to notice transitions.
+type eventTracker struct {
+	states  map[string]string   // Last seen value of each field
+	seen    map[string]struct{} // Fields set since the last prune
+	pending []system.Event      // Events not yet sent to the hub
+	mutex   sync.Mutex
+}
+
+func newEventTracker() *eventTracker {
+	return &eventTracker{states: make(map[string]string), seen: make(map[string]struct{})}
+}
+
+// Records the current value of a field. An event is created if the value
+// differs from the previous one. The first value seen is only a baseline.
+func (et *eventTracker) set(name, value string) {
+	et.mutex.Lock()
+	defer et.mutex.Unlock()
+	prev, seen := et.states[name]
+	et.states[name] = value
+	et.seen[name] = struct{}{}
+	if !seen || prev == value {
+		return
+	}
+	if len(et.pending) >= maxPendingEvents {
+		et.pending = et.pending[1:]
+	}
+	et.pending = append(et.pending, system.Event{
+		Time:  time.Now().Unix(),
+		Name:  name,
+		Prev:  prev,
+		Value: value,
+	})
+}
+
+func (et *eventTracker) setBool(name string, value bool) {
+	et.set(name, strconv.FormatBool(value))
+}
+
+// Forgets fields that weren't set since the last prune, such as removed
+// containers or interfaces, so states doesn't grow without limit
+func (et *eventTracker) prune() {
+	et.mutex.Lock()
+	defer et.mutex.Unlock()
+	for name := range et.states {
+		if _, ok := et.seen[name]; !ok {
+			delete(et.states, name)
+		}
+	}
+	clear(et.seen)
+}
+
+// Returns and clears the pending events
+func (et *eventTracker) drain() []system.Event {
+	et.mutex.Lock()
+	defer et.mutex.Unlock()
+	events := et.pending
+	et.pending = nil
+	return events
+}
+
+// Feeds event-like fields from the collected data into the tracker
+func (a *Agent) trackEvents(data *system.CombinedData) {
+	if docker := data.Info.Docker; docker != nil {
+		a.events.setBool("docker.available", docker.Available)
+	}
+	if data.Info.BootTime > 0 {
+		a.events.set("system.boot", strconv.FormatInt(data.Info.BootTime, 10))
+	}
+	if data.Info.SystemState != "" {
+		a.events.set("system.state", data.Info.SystemState)
+	}
+	if dns := data.Info.Dns; dns != nil {
+		a.events.setBool("dns.ok", dns.Ok)
+	}
+	for name, health := range data.Info.DiskHealth {
+		a.events.setBool("smart."+name+".passed", health.Passed)
+	}
+	for name, iface := range data.Info.NetInterfaces {
+		a.events.setBool("net."+name+".up", iface.Up)
+	}
+	for name, fs := range a.fsStats {
+		a.events.setBool("disk."+name+".timedout", fs.TimedOut)
+	}
+	for _, ctr := range data.Containers {
+		if ctr.Health != "" {
+			a.events.set("container."+ctr.Name+".health", ctr.Health)
+		}
+		a.events.setBool("container."+ctr.Name+".oom", ctr.OomKills > 0)
+	}
+	if a.dockerManager != nil && a.dockerManager.imageUpdates != nil {
+		for _, ctr := range data.Containers {
+			a.events.setBool("container."+ctr.Name+".update", ctr.UpdateAvailable)
+		}
+	}
+	a.events.prune()
+}
@@ -0,0 +1,28 @@
+package agent
+
+import "testing"
+
+func TestEventTrackerPrune(t *testing.T) {
+	et := newEventTracker()
+	et.set("net.eth0.up", "true")
+	et.set("net.eth1.up", "true")
+	et.prune()
+
+	// eth1 disappears for one collection, so its baseline is forgotten
+	et.set("net.eth0.up", "true")
+	et.prune()
+	if _, ok := et.states["net.eth1.up"]; ok {
+		t.Fatal("state of unseen field wasn't pruned")
+	}
+	if len(et.states) != 1 {
+		t.Fatalf("states = %v; want only net.eth0.up", et.states)
+	}
+
+	// a field that comes back starts a new baseline instead of an event
+	et.set("net.eth1.up", "false")
+	et.set("net.eth0.up", "false")
+	events := et.drain()
+	if len(events) != 1 || events[0].Name != "net.eth0.up" {
+		t.Fatalf("events = %+v; want one event for net.eth0.up", events)
+	}
+}
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/common"
+)
+
+// Returns the sysfs root, honoring the SYS_SENSORS override in the sensors context
+func sysfsRoot(ctx context.Context) string {
+	if env, ok := ctx.Value(common.EnvKey).(common.EnvMap); ok {
+		if path := env[common.HostSysEnvKey]; path != "" {
+			return path
+		}
+	}
+	if path := os.Getenv("HOST_SYS"); path != "" {
+		return path
+	}
+	return "/sys"
+}
+
+// Reads fan speeds (RPM) from hwmon, since gopsutil only reports temperatures.
+// Keys are the chip name and fan label, e.g. nct6798_cpu_fan, with an index
+// appended if two fans share a key.
+func getFanSpeeds(ctx context.Context) map[string]float64 {
+	inputs, _ := filepath.Glob(filepath.Join(sysfsRoot(ctx), "class", "hwmon", "hwmon*", "fan*_input"))
+	if len(inputs) == 0 {
+		return nil
+	}
+	fans := make(map[string]float64, len(inputs))
+	for i, input := range inputs {
+		rpm, err := strconv.ParseFloat(readSysfsString(input), 64)
+		if err != nil {
+			continue
+		}
+		prefix := strings.TrimSuffix(input, "_input")
+		label := readSysfsString(prefix + "_label")
+		if label == "" {
+			label = filepath.Base(prefix)
+		}
+		key := readSysfsString(filepath.Join(filepath.Dir(input), "name")) + "_" + label
+		key = strings.ReplaceAll(strings.ToLower(key), " ", "_")
+		if _, ok := fans[key]; ok {
+			// if key already exists, append int to key
+			key += "_" + strconv.Itoa(i)
+		}
+		fans[key] = rpm
+	}
+	return fans
+}
@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"strconv"
+)
+
+// Returns the stats as a flat map with stable dotted keys, for generic
+// time-series tools. See "Flattened stats" in the readme for the key names.
+// With baseUnits, sizes are in bytes and rates in bytes per second.
+func flattenStats(data *system.CombinedData, baseUnits bool) map[string]float64 {
+	gb, mb := 1.0, 1.0
+	if baseUnits {
+		gb, mb = 1<<30, 1<<20
+	}
+	stats := &data.Stats
+	flat := map[string]float64{
+		"cpu":           stats.Cpu,
+		"cpu.iowait":    stats.CpuIowait,
+		"cpu.steal":     stats.CpuSteal,
+		"cpu.ctxsw":     stats.ContextSwitchesPs,
+		"cpu.intr":      stats.InterruptsPs,
+		"load.1":        stats.LoadAvg1,
+		"load.5":        stats.LoadAvg5,
+		"load.15":       stats.LoadAvg15,
+		"load.percore":  stats.LoadPerCore,
+		"procs":         float64(stats.ProcessCount),
+		"threads":       float64(stats.ThreadCount),
+		"zombies":       float64(stats.ZombieCount),
+		"mem.total":     stats.Mem * gb,
+		"mem.used":      stats.MemUsed * gb,
+		"mem.pct":       stats.MemPct,
+		"mem.buffcache": stats.MemBuffCache * gb,
+		"swap.total":    stats.Swap * gb,
+		"swap.used":     stats.SwapUsed * gb,
+		"disk./.total":  stats.DiskTotal * gb,
+		"disk./.used":   stats.DiskUsed * gb,
+		"disk./.pct":    stats.DiskPct,
+		"disk./.read":   stats.DiskReadPs * mb,
+		"disk./.write":  stats.DiskWritePs * mb,
+		"disk./.util":   stats.DiskUtil,
+		"disk./.rops":   stats.DiskReadOpsPs,
+		"disk./.wops":   stats.DiskWriteOpsPs,
+		"disk./.await":  stats.DiskAwait,
+		"disk./.errors": float64(stats.DiskErrors),
+		"disk./.inodes": stats.InodesPct,
+		"net.sent":      stats.NetworkSent * mb,
+		"net.recv":      stats.NetworkRecv * mb,
+		"net.errors":    stats.NetworkErrorsPs,
+		"net.drops":     stats.NetworkDropsPs,
+		"uptime":        float64(data.Info.Uptime),
+	}
+	for i, pct := range stats.CpuPerCore {
+		flat["cpu.core."+strconv.Itoa(i)] = pct
+	}
+	if stats.DiskLatencyP99 > 0 {
+		flat["disk./.p99"] = stats.DiskLatencyP99
+	}
+	if stats.MemZfsArc > 0 {
+		flat["mem.zfsarc"] = stats.MemZfsArc * gb
+	}
+	if stats.MemAnon > 0 {
+		flat["mem.anon"] = stats.MemAnon * gb
+		flat["mem.pagecache"] = stats.MemPageCache * gb
+		flat["mem.slab"] = stats.MemSlab * gb
+	}
+	if stats.MemThrashing > 0 {
+		flat["mem.thrashing"] = stats.MemThrashing
+	}
+	if p := stats.Pressure; p != nil {
+		flat["psi.cpu.some"] = p.CpuSome
+		flat["psi.cpu.full"] = p.CpuFull
+		flat["psi.mem.some"] = p.MemSome
+		flat["psi.mem.full"] = p.MemFull
+		flat["psi.io.some"] = p.IoSome
+		flat["psi.io.full"] = p.IoFull
+	}
+	if stats.ConntrackMax > 0 {
+		flat["conntrack.count"] = float64(stats.ConntrackCount)
+		flat["conntrack.max"] = float64(stats.ConntrackMax)
+		flat["conntrack.pct"] = stats.ConntrackPct
+	}
+	if stats.SwapInPs > 0 || stats.SwapOutPs > 0 {
+		flat["swap.in"] = stats.SwapInPs * mb
+		flat["swap.out"] = stats.SwapOutPs * mb
+	}
+	if stats.MemBandwidth > 0 {
+		flat["mem.bandwidth"] = stats.MemBandwidth * gb
+	}
+	for name, fs := range stats.ExtraFs {
+		prefix := "disk." + name + "."
+		flat[prefix+"total"] = fs.DiskTotal * gb
+		flat[prefix+"used"] = fs.DiskUsed * gb
+		if fs.DiskTotal > 0 {
+			flat[prefix+"pct"] = twoDecimals(fs.DiskUsed / fs.DiskTotal * 100)
+		}
+		flat[prefix+"read"] = fs.DiskReadPs * mb
+		flat[prefix+"write"] = fs.DiskWritePs * mb
+		flat[prefix+"util"] = fs.DiskUtil
+		flat[prefix+"rops"] = fs.DiskReadOpsPs
+		flat[prefix+"wops"] = fs.DiskWriteOpsPs
+		flat[prefix+"await"] = fs.DiskAwait
+		flat[prefix+"errors"] = float64(fs.FsErrors)
+		flat[prefix+"inodes"] = fs.InodesPct
+		if fs.LatencyP99 > 0 {
+			flat[prefix+"p99"] = fs.LatencyP99
+		}
+	}
+	for state, count := range stats.Connections {
+		flat["conn."+state] = float64(count)
+	}
+	for name, iface := range stats.Interfaces {
+		flat["net."+name+".sent"] = iface.NetworkSent * mb
+		flat["net."+name+".recv"] = iface.NetworkRecv * mb
+	}
+	for name, ns := range stats.NetNs {
+		flat["netns."+name+".sent"] = ns.NetworkSent * mb
+		flat["netns."+name+".recv"] = ns.NetworkRecv * mb
+	}
+	for name, temp := range stats.Temperatures {
+		flat["temp."+name] = temp
+	}
+	for name, rpm := range stats.Fans {
+		flat["fan."+name] = rpm
+	}
+	for id, gpu := range stats.GPUData {
+		prefix := "gpu." + id + "."
+		flat[prefix+"usage"] = gpu.Usage
+		flat[prefix+"mem.used"] = gpu.MemoryUsed * mb
+		flat[prefix+"mem.total"] = gpu.MemoryTotal * mb
+		flat[prefix+"power"] = gpu.Power
+	}
+	for name, value := range stats.Custom {
+		flat["custom."+name] = value
+	}
+	for name, sensor := range stats.Ipmi {
+		flat["ipmi."+name] = sensor.Value
+	}
+	for _, ctr := range data.Containers {
+		prefix := "container." + ctr.Name + "."
+		flat[prefix+"cpu"] = ctr.Cpu
+		flat[prefix+"mem"] = ctr.Mem * mb
+		flat[prefix+"swap"] = ctr.SwapUsed * mb
+		flat[prefix+"net.sent"] = ctr.NetworkSent * mb
+		flat[prefix+"net.recv"] = ctr.NetworkRecv * mb
+		flat[prefix+"mem.pct"] = ctr.MemPct
+		flat[prefix+"restarts"] = float64(ctr.Restarts)
+		flat[prefix+"uptime"] = float64(ctr.Uptime)
+		flat[prefix+"oom.kills"] = float64(ctr.OomKills)
+		flat[prefix+"disk.read"] = ctr.DiskReadPs * mb
+		flat[prefix+"disk.write"] = ctr.DiskWritePs * mb
+		if ctr.Replicas > 0 {
+			flat[prefix+"replicas"] = float64(ctr.Replicas)
+		}
+		if ctr.MemDetail != nil {
+			flat[prefix+"mem.rss"] = ctr.MemDetail.Rss * mb
+			flat[prefix+"mem.cache"] = ctr.MemDetail.Cache * mb
+			flat[prefix+"mem.mapped"] = ctr.MemDetail.Mapped * mb
+		}
+		if ctr.LogErrors > 0 {
+			flat[prefix+"log.errors"] = ctr.LogErrors
+		}
+		if ctr.Sockets != nil {
+			flat[prefix+"sockets.established"] = float64(ctr.Sockets.Established)
+			flat[prefix+"sockets.listen"] = float64(ctr.Sockets.Listen)
+			flat[prefix+"sockets.timewait"] = float64(ctr.Sockets.TimeWait)
+		}
+	}
+	for _, vm := range data.VMs {
+		prefix := "vm." + vm.Name + "."
+		flat[prefix+"cpu"] = vm.Cpu
+		flat[prefix+"mem"] = vm.Mem * mb
+	}
+	if states := data.Info.Containers; states != nil {
+		flat["containers.running"] = float64(states.Running)
+		flat["containers.stopped"] = float64(states.Stopped)
+		flat["containers.paused"] = float64(states.Paused)
+		flat["containers.restarting"] = float64(states.Restarting)
+	}
+	for _, u := range data.Info.Users {
+		prefix := "user." + u.Name + "."
+		flat[prefix+"cpu"] = u.Cpu
+		flat[prefix+"mem"] = u.Mem * mb
+		flat[prefix+"procs"] = float64(u.Procs)
+	}
+	if data.Info.CpuFreq > 0 {
+		flat["cpu.freq"] = data.Info.CpuFreq
+	}
+	if bat := data.Info.Battery; bat != nil {
+		flat["battery.pct"] = bat.Percent
+		flat["battery.charging"] = 0
+		if bat.Charging {
+			flat["battery.charging"] = 1
+		}
+	}
+	if pc := data.Info.PowerCap; pc != nil {
+		flat["powercap.long"] = pc.LongTerm
+		flat["powercap.short"] = pc.ShortTerm
+	}
+	for name, health := range data.Info.DiskHealth {
+		prefix := "smart." + name + "."
+		flat[prefix+"ok"] = 0
+		if health.Passed {
+			flat[prefix+"ok"] = 1
+		}
+		flat[prefix+"realloc"] = float64(health.Reallocated)
+		flat[prefix+"pending"] = float64(health.Pending)
+		flat[prefix+"temp"] = health.Temperature
+	}
+	if rt := data.Info.AgentRuntime; rt != nil {
+		flat["agent.goroutines"] = float64(rt.Goroutines)
+		flat["agent.heap"] = rt.HeapAlloc * mb
+	}
+	if dns := data.Info.Dns; dns != nil {
+		flat["dns.ok"] = 0
+		if dns.Ok {
+			flat["dns.ok"] = 1
+		}
+		flat["dns.latency"] = dns.Latency
+	}
+	return flat
+}
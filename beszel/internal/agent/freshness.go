@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"slices"
+	"time"
+)
+
+// Records when each section served from a background collector was last
+// updated, and flags sections older than the stale threshold so the hub can
+// tell that the values aren't current.
+func (a *Agent) updateFreshness() {
+	sections := make(map[string]time.Time)
+	if a.ipmiManager != nil {
+		sections["ipmi"] = a.ipmiManager.lastUpdate()
+	}
+	if a.connTracker != nil {
+		sections["connections"] = a.connTracker.lastUpdate()
+	}
+	if a.processManager != nil {
+		sections["processes"] = a.processManager.lastUpdate()
+	}
+	if a.userStatsManager != nil {
+		sections["users"] = a.userStatsManager.lastUpdate()
+	}
+	if a.dnsProbe != nil {
+		sections["dns"] = a.dnsProbe.lastUpdate()
+	}
+	if a.smartManager != nil {
+		sections["smart"] = a.smartManager.lastUpdate()
+	}
+	if a.systemdManager != nil {
+		sections["systemd"] = a.systemdManager.lastUpdate()
+	}
+	if len(sections) == 0 {
+		return
+	}
+
+	a.systemInfo.Updated = make(map[string]int64, len(sections))
+	a.systemInfo.Stale = nil
+	for name, updated := range sections {
+		if updated.IsZero() || time.Since(updated) > a.staleThreshold {
+			a.systemInfo.Stale = append(a.systemInfo.Stale, name)
+		}
+		if !updated.IsZero() {
+			a.systemInfo.Updated[name] = updated.Unix()
+		}
+	}
+	slices.Sort(a.systemInfo.Stale)
+}
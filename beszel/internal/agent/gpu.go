@@ -14,9 +14,14 @@ import (
 	"golang.org/x/exp/slog"
 )
 
+// Consecutive nvidia-smi failures without output before giving up, e.g. when
+// the driver is installed but no GPU is present or the driver is mismatched
+const maxNvidiaFailures = 5
+
 type GPUManager struct {
 	nvidiaSmi  bool
 	rocmSmi    bool
+	amdCards   []amdSysfsCard // AMD cards read from sysfs when rocm-smi isn't installed
 	GpuDataMap map[string]*system.GPUData
 	mutex      sync.Mutex
 }
@@ -31,29 +36,40 @@ type RocmSmiJson struct {
 	Power       string `json:"Current Socket Graphics Package Power (W)"`
 }
 
-// startNvidiaCollector oversees collectNvidiaStats and restarts nvidia-smi if it fails
-func (gm *GPUManager) startNvidiaCollector() error {
+// startNvidiaCollector oversees collectNvidiaStats and restarts nvidia-smi if it fails.
+// Gives up after repeated failures that produced no data, so nvidia-smi isn't
+// respawned forever on hosts where it can't read a GPU.
+func (gm *GPUManager) startNvidiaCollector() {
+	failures := 0
 	for {
-		if err := gm.collectNvidiaStats(); err != nil {
-			slog.Warn("Restarting nvidia-smi", "err", err)
-			time.Sleep(time.Second) // Wait before retrying
+		parsed, err := gm.collectNvidiaStats()
+		if err == nil {
 			continue
 		}
+		if parsed {
+			failures = 0
+		} else if failures++; failures >= maxNvidiaFailures {
+			slog.Warn("Stopping nvidia-smi", "err", err, "failures", failures)
+			return
+		}
+		slog.Warn("Restarting nvidia-smi", "err", err)
+		time.Sleep(time.Second) // Wait before retrying
 	}
 }
 
-// collectNvidiaStats runs nvidia-smi in a loop and passes the output to parseNvidiaData
-func (gm *GPUManager) collectNvidiaStats() error {
+// collectNvidiaStats runs nvidia-smi in a loop and passes the output to parseNvidiaData.
+// Returns whether any output was read before nvidia-smi exited.
+func (gm *GPUManager) collectNvidiaStats() (parsed bool, err error) {
 	// Set up the command
 	cmd := exec.Command("nvidia-smi", "-l", "4", "--query-gpu=index,name,temperature.gpu,memory.used,memory.total,utilization.gpu,power.draw", "--format=csv,noheader,nounits")
 	// Set up a pipe to capture stdout
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return err
+		return false, err
 	}
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		return err
+		return false, err
 	}
 	// Use a scanner to read each line of output
 	scanner := bufio.NewScanner(stdout)
@@ -62,13 +78,14 @@ func (gm *GPUManager) collectNvidiaStats() error {
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		gm.parseNvidiaData(line) // Run your function on each new line
+		parsed = true
 	}
 	// Check for any errors encountered during scanning
 	if err := scanner.Err(); err != nil {
-		return err
+		return parsed, err
 	}
 	// Wait for the command to complete
-	return cmd.Wait()
+	return parsed, cmd.Wait()
 }
 
 // parseNvidiaData parses the output of nvidia-smi and updates the GPUData map
@@ -206,10 +223,13 @@ func (gm *GPUManager) detectGPU() error {
 	if err := exec.Command("rocm-smi").Run(); err == nil {
 		gm.rocmSmi = true
 	}
-	if gm.nvidiaSmi || gm.rocmSmi {
+	if !gm.rocmSmi {
+		gm.amdCards = findAmdSysfsCards()
+	}
+	if gm.nvidiaSmi || gm.rocmSmi || len(gm.amdCards) > 0 {
 		return nil
 	}
-	return fmt.Errorf("no GPU found - install nvidia-smi or rocm-smi")
+	return fmt.Errorf("no GPU found - install nvidia-smi or rocm-smi, or load the amdgpu driver")
 }
 
 // NewGPUManager returns a new GPUManager
@@ -225,5 +245,8 @@ func NewGPUManager() (*GPUManager, error) {
 	if gm.rocmSmi {
 		go gm.startAmdCollector()
 	}
+	if len(gm.amdCards) > 0 {
+		go gm.startAmdSysfsCollector()
+	}
 	return &gm, nil
 }
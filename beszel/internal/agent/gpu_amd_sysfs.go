@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	drmClassDir = "/sys/class/drm"
+	amdVendorId = "0x1002"
+)
+
+// An AMD card exposed by the amdgpu driver in sysfs
+type amdSysfsCard struct {
+	index int
+	dir   string // e.g. /sys/class/drm/card0/device
+}
+
+// Returns the amdgpu cards that report utilization in sysfs, ordered by card index
+func findAmdSysfsCards() []amdSysfsCard {
+	var cards []amdSysfsCard
+	paths, _ := filepath.Glob(filepath.Join(drmClassDir, "card*", "device", "gpu_busy_percent"))
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		// skip connectors such as card0-DP-1
+		index, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(dir)), "card"))
+		if err != nil || readSysfsString(filepath.Join(dir, "vendor")) != amdVendorId {
+			continue
+		}
+		cards = append(cards, amdSysfsCard{index: index, dir: dir})
+	}
+	slices.SortFunc(cards, func(a, b amdSysfsCard) int { return a.index - b.index })
+	return cards
+}
+
+// startAmdSysfsCollector reads the cards' sysfs stats on the same interval as rocm-smi
+func (gm *GPUManager) startAmdSysfsCollector() {
+	for {
+		gm.parseAmdSysfsData()
+		time.Sleep(4300 * time.Millisecond)
+	}
+}
+
+// parseAmdSysfsData reads utilization, VRAM, temperature, and power of each card
+// and updates the GPUData map
+func (gm *GPUManager) parseAmdSysfsData() {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+	for _, card := range gm.amdCards {
+		usage, err := strconv.ParseFloat(readSysfsString(filepath.Join(card.dir, "gpu_busy_percent")), 64)
+		if err != nil {
+			continue
+		}
+		memoryUsed, _ := strconv.ParseFloat(readSysfsString(filepath.Join(card.dir, "mem_info_vram_used")), 64)
+		memoryTotal, _ := strconv.ParseFloat(readSysfsString(filepath.Join(card.dir, "mem_info_vram_total")), 64)
+
+		id := "card" + strconv.Itoa(card.index)
+		if _, ok := gm.GpuDataMap[id]; !ok {
+			name := readSysfsString(filepath.Join(card.dir, "product_name"))
+			if name == "" {
+				name = "AMD GPU " + strconv.Itoa(card.index)
+			}
+			gm.GpuDataMap[id] = &system.GPUData{Name: name}
+		}
+		gpu := gm.GpuDataMap[id]
+		temperature, power := readAmdHwmon(card.dir)
+		// temperature is the latest reading, power and usage are averaged by GetCurrentData
+		gpu.Temperature = temperature
+		gpu.MemoryUsed = bytesToMegabytes(memoryUsed)
+		gpu.MemoryTotal = bytesToMegabytes(memoryTotal)
+		gpu.Usage += usage
+		gpu.Power += power
+		gpu.Count++
+	}
+}
+
+// Reads the edge temperature (°C) and power draw (W) from the card's hwmon directory
+func readAmdHwmon(cardDir string) (temperature, power float64) {
+	hwmons, _ := filepath.Glob(filepath.Join(cardDir, "hwmon", "hwmon*"))
+	if len(hwmons) == 0 {
+		return 0, 0
+	}
+	if millidegrees, err := strconv.ParseFloat(readSysfsString(filepath.Join(hwmons[0], "temp1_input")), 64); err == nil {
+		temperature = millidegrees / 1000
+	}
+	// newer kernels report power1_input instead of power1_average
+	for _, name := range []string{"power1_average", "power1_input"} {
+		if microwatts, err := strconv.ParseFloat(readSysfsString(filepath.Join(hwmons[0], name)), 64); err == nil {
+			power = microwatts / 1e6
+			break
+		}
+	}
+	return temperature, power
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Serves the stats as json on /stats if HTTP_PORT is set. The value may be a
+// port or a host:port address.
+func (a *Agent) startHttpServer() {
+	addr, exists := os.LookupEnv("HTTP_PORT")
+	if !exists || addr == "" {
+		return
+	}
+	if os.Getenv("HTTP_TOKEN") == "" {
+		slog.Warn("HTTP_TOKEN not set, stats are readable without authentication")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", requireToken(func(w http.ResponseWriter, r *http.Request) {
+		// events are left for the hub, which is the only consumer that drains them
+		stats := a.collectStats()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			slog.Error("Error encoding stats", "err", err)
+		}
+	}))
+	a.httpServers = append(a.httpServers, listenHttp("HTTP", addr, mux))
+}
+
+// Wraps a handler to require HTTP_TOKEN as a bearer token, if it is set
+func requireToken(next http.HandlerFunc) http.HandlerFunc {
+	token := os.Getenv("HTTP_TOKEN")
+	if token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Starts an http server in the background. A bare port listens on all addresses.
+func listenHttp(name, addr string, handler http.Handler) *http.Server {
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	slog.Info("Starting "+name+" server", "address", addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Error starting "+name+" server", "err", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,274 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Media types accepted when requesting a manifest digest from a registry
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+type imageUpdateStatus struct {
+	available bool
+	checked   time.Time
+}
+
+// Checks whether newer images are available in the registry for running containers.
+// Results are cached per image and refreshed in the background on a slow interval.
+type imageUpdateChecker struct {
+	dockerClient   *http.Client                  // Client to query Docker API for local image digests
+	registryClient *http.Client                  // Client to query registries
+	interval       time.Duration                 // How long to cache results
+	status         map[string]*imageUpdateStatus // Cached status for each image reference
+	checking       map[string]struct{}           // Images with a check in progress
+	mutex          sync.Mutex
+}
+
+func newImageUpdateChecker(dockerClient *http.Client) *imageUpdateChecker {
+	interval := 6 * time.Hour
+	if t, set := os.LookupEnv("IMAGE_UPDATES_INTERVAL"); set {
+		if d, err := time.ParseDuration(t); err == nil && d > 0 {
+			interval = d
+		} else {
+			slog.Warn("Invalid IMAGE_UPDATES_INTERVAL", "value", t)
+		}
+	}
+	return &imageUpdateChecker{
+		dockerClient:   dockerClient,
+		registryClient: &http.Client{Timeout: 10 * time.Second},
+		interval:       interval,
+		status:         make(map[string]*imageUpdateStatus),
+		checking:       make(map[string]struct{}),
+	}
+}
+
+// Returns the cached update status for an image and starts a background
+// check if the cached value is older than the interval
+func (ic *imageUpdateChecker) updateAvailable(image string) bool {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	status, ok := ic.status[image]
+	if !ok {
+		status = &imageUpdateStatus{}
+		ic.status[image] = status
+	}
+	if _, running := ic.checking[image]; !running && time.Since(status.checked) > ic.interval {
+		ic.checking[image] = struct{}{}
+		go ic.check(image)
+	}
+	return status.available
+}
+
+// Compares the local image digests with the registry's digest for the tag
+func (ic *imageUpdateChecker) check(image string) {
+	available, err := ic.compareDigests(image)
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	delete(ic.checking, image)
+	status, ok := ic.status[image]
+	if !ok {
+		// pruned while checking
+		return
+	}
+	// record the attempt even on failure so unreachable registries aren't hammered
+	status.checked = time.Now()
+	if err != nil {
+		slog.Debug("Image update check failed", "image", image, "err", err)
+		return
+	}
+	status.available = available
+}
+
+// Removes cached status of images no running container uses
+func (ic *imageUpdateChecker) prune(containers []container.ApiInfo) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	images := make(map[string]struct{}, len(containers))
+	for _, ctr := range containers {
+		if ctr.State == "running" {
+			images[ctr.Image] = struct{}{}
+		}
+	}
+	for image := range ic.status {
+		if _, ok := images[image]; !ok {
+			delete(ic.status, image)
+		}
+	}
+}
+
+func (ic *imageUpdateChecker) compareDigests(image string) (bool, error) {
+	registry, repository, tag, err := parseImageReference(image)
+	if err != nil {
+		return false, err
+	}
+	localDigests, err := ic.localDigests(image)
+	if err != nil {
+		return false, err
+	}
+	if len(localDigests) == 0 {
+		return false, errors.New("no repo digest (image was not pulled from a registry)")
+	}
+	remoteDigest, err := ic.remoteDigest(registry, repository, tag)
+	if err != nil {
+		return false, err
+	}
+	for _, digest := range localDigests {
+		if digest == remoteDigest {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Returns the digests the local image was pulled with
+func (ic *imageUpdateChecker) localDigests(image string) ([]string, error) {
+	resp, err := ic.dockerClient.Get("http://localhost/images/" + image + "/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image inspect returned %s", resp.Status)
+	}
+	var inspect struct {
+		RepoDigests []string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return nil, err
+	}
+	digests := make([]string, 0, len(inspect.RepoDigests))
+	for _, repoDigest := range inspect.RepoDigests {
+		// Example: nginx@sha256:0123abcd...
+		if _, digest, found := strings.Cut(repoDigest, "@"); found {
+			digests = append(digests, digest)
+		}
+	}
+	return digests, nil
+}
+
+// Returns the registry's current digest for a tag, requesting an anonymous token if required
+func (ic *imageUpdateChecker) remoteDigest(registry, repository, tag string) (string, error) {
+	manifestUrl := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, tag)
+	resp, err := ic.headManifest(manifestUrl, "")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := ic.anonymousToken(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", err
+		}
+		if resp, err = ic.headManifest(manifestUrl, token); err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		// includes 429 rate limits, which are retried after the next interval
+		return "", fmt.Errorf("registry returned %s", resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", errors.New("registry did not return a digest")
+	}
+	return digest, nil
+}
+
+func (ic *imageUpdateChecker) headManifest(manifestUrl, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, manifestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return ic.registryClient.Do(req)
+}
+
+// Requests an anonymous pull token using the challenge from a 401 response.
+// Example challenge: Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func (ic *imageUpdateChecker) anonymousToken(challenge string) (string, error) {
+	params, found := strings.CutPrefix(challenge, "Bearer ")
+	if !found {
+		return "", errors.New("unsupported registry auth")
+	}
+	values := make(map[string]string)
+	for _, param := range strings.Split(params, ",") {
+		if key, value, found := strings.Cut(strings.TrimSpace(param), "="); found {
+			values[key] = strings.Trim(value, `"`)
+		}
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || realm.Scheme != "https" {
+		return "", fmt.Errorf("invalid auth realm %q", values["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	resp, err := ic.registryClient.Get(realm.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %s", resp.Status)
+	}
+	var tokenResponse struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+	if tokenResponse.Token != "" {
+		return tokenResponse.Token, nil
+	}
+	return tokenResponse.AccessToken, nil
+}
+
+// Splits an image reference into registry host, repository, and tag.
+// Example: nginx -> registry-1.docker.io, library/nginx, latest
+func parseImageReference(image string) (registry, repository, tag string, err error) {
+	if strings.Contains(image, "@") || strings.HasPrefix(image, "sha256:") {
+		return "", "", "", errors.New("image is pinned to a digest")
+	}
+	registry = "registry-1.docker.io"
+	repository = image
+	// first component is a registry if it looks like a host
+	if host, rest, found := strings.Cut(image, "/"); found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		registry = host
+		repository = rest
+	}
+	tag = "latest"
+	if i := strings.LastIndex(repository, ":"); i > 0 {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	if registry == "docker.io" || registry == "index.docker.io" {
+		registry = "registry-1.docker.io"
+	}
+	if registry == "registry-1.docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository, tag, nil
+}
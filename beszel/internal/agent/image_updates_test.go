@@ -0,0 +1,26 @@
+package agent
+
+import "testing"
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image, registry, repository, tag string
+	}{
+		{"nginx", "registry-1.docker.io", "library/nginx", "latest"},
+		{"nginx:1.27", "registry-1.docker.io", "library/nginx", "1.27"},
+		{"docker.io/nginx", "registry-1.docker.io", "library/nginx", "latest"},
+		{"index.docker.io/nginx:1.27", "registry-1.docker.io", "library/nginx", "1.27"},
+		{"docker.io/henrygd/beszel", "registry-1.docker.io", "henrygd/beszel", "latest"},
+		{"ghcr.io/owner/app:v1", "ghcr.io", "owner/app", "v1"},
+		{"localhost:5000/app", "localhost:5000", "app", "latest"},
+	}
+	for _, tt := range tests {
+		registry, repository, tag, err := parseImageReference(tt.image)
+		if err != nil || registry != tt.registry || repository != tt.repository || tag != tt.tag {
+			t.Errorf("parseImageReference(%q) = %q, %q, %q, %v; want %q, %q, %q", tt.image, registry, repository, tag, err, tt.registry, tt.repository, tt.tag)
+		}
+	}
+	if _, _, _, err := parseImageReference("nginx@sha256:abcd"); err == nil {
+		t.Error("expected an error for an image pinned to a digest")
+	}
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"errors"
+	"sync"
+	"time"
+)
+
+// How long inspect results are reused before querying the container again
+const inspectCacheTTL = 5 * time.Minute
+
+type inspectCacheEntry struct {
+	info    *container.ApiInspect
+	expires time.Time
+}
+
+// Caches container inspect results, which rarely change
+type inspectCache struct {
+	entries map[string]inspectCacheEntry
+	mutex   sync.Mutex
+}
+
+// Returns inspect details for the container, from cache if fresh
+func (dm *dockerManager) inspectContainer(id string) (*container.ApiInspect, error) {
+	dm.inspect.mutex.Lock()
+	entry, ok := dm.inspect.entries[id]
+	dm.inspect.mutex.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.info, nil
+	}
+
+	// errors and unexpected responses aren't cached so the next update retries
+	info := &container.ApiInspect{}
+	if err := dm.getJson("/containers/"+id+"/json", info); err != nil {
+		return nil, err
+	}
+	if info.Id == "" {
+		return nil, errors.New("inspect response has no container id")
+	}
+
+	dm.inspect.mutex.Lock()
+	defer dm.inspect.mutex.Unlock()
+	if dm.inspect.entries == nil {
+		dm.inspect.entries = make(map[string]inspectCacheEntry)
+	}
+	dm.inspect.entries[id] = inspectCacheEntry{info: info, expires: time.Now().Add(inspectCacheTTL)}
+	return info, nil
+}
+
+// Removes the cached inspect result for the container (e.g. after a restart changes its pid)
+func (dm *dockerManager) deleteInspectSync(id string) {
+	dm.inspect.mutex.Lock()
+	defer dm.inspect.mutex.Unlock()
+	delete(dm.inspect.entries, id)
+}
+
+// Removes cached inspect results for containers that are no longer running
+func (dm *dockerManager) pruneInspectCache() {
+	dm.inspect.mutex.Lock()
+	defer dm.inspect.mutex.Unlock()
+	for id := range dm.inspect.entries {
+		if _, ok := dm.validIds[id]; !ok {
+			delete(dm.inspect.entries, id)
+		}
+	}
+}
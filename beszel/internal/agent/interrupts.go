@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Context switch and interrupt counters from the previous collection
+type switchCounters struct {
+	ctxt uint64
+	intr uint64
+	time time.Time
+}
+
+// Sets context switches and interrupts per second from /proc/stat. Leaves
+// them at zero on the first call, if a counter reset, or on other platforms.
+func (a *Agent) setSwitchRates(systemStats *system.Stats) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return
+	}
+	var current switchCounters
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// the intr line lists every irq, so it can be long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ctxt":
+			current.ctxt, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "intr":
+			current.intr, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	current.time = time.Now()
+	prev := a.prevSwitches
+	a.prevSwitches = current
+	if prev.time.IsZero() {
+		return
+	}
+	ctxtDelta, ctxtOk := counterDelta(prev.ctxt, current.ctxt)
+	intrDelta, intrOk := counterDelta(prev.intr, current.intr)
+	secondsElapsed := current.time.Sub(prev.time).Seconds()
+	if !ctxtOk || !intrOk || secondsElapsed <= 0 {
+		return
+	}
+	systemStats.ContextSwitchesPs = twoDecimals(float64(ctxtDelta) / secondsElapsed)
+	systemStats.InterruptsPs = twoDecimals(float64(intrDelta) / secondsElapsed)
+}
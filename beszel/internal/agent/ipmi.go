@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ipmiInterval = 30 * time.Second // How often to run ipmitool, which can take several seconds to query the BMC
+	ipmiTimeout  = 20 * time.Second // Time to wait for an unresponsive BMC
+)
+
+type ipmiManager struct {
+	sensors map[string]system.IpmiSensor
+	updated time.Time // Time of the last successful read
+	mutex   sync.Mutex
+}
+
+// Returns a new ipmiManager and starts collecting in the background,
+// or nil if ipmitool is missing or there is no BMC
+func newIpmiManager(ctx context.Context) *ipmiManager {
+	sensors, err := readIpmiSensors()
+	if err != nil {
+		slog.Debug("IPMI", "err", err)
+		return nil
+	}
+	im := &ipmiManager{sensors: sensors, updated: time.Now()}
+	go im.startCollector(ctx)
+	return im
+}
+
+// Refreshes sensor data on an interval
+func (im *ipmiManager) startCollector(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ipmiInterval):
+		}
+		sensors, err := readIpmiSensors()
+		if err != nil {
+			slog.Warn("Error reading IPMI sensors", "err", err)
+			continue
+		}
+		im.mutex.Lock()
+		im.sensors = sensors
+		im.updated = time.Now()
+		im.mutex.Unlock()
+	}
+}
+
+// Returns a copy of the latest sensor data
+func (im *ipmiManager) getSensors() map[string]system.IpmiSensor {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+	sensors := make(map[string]system.IpmiSensor, len(im.sensors))
+	for name, sensor := range im.sensors {
+		sensors[name] = sensor
+	}
+	return sensors
+}
+
+// Returns the time of the last successful read
+func (im *ipmiManager) lastUpdate() time.Time {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+	return im.updated
+}
+
+// Runs `ipmitool sensor` and parses sensors that have a reading
+func readIpmiSensors() (map[string]system.IpmiSensor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ipmiTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "ipmitool", "sensor").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseIpmiSensors(output), nil
+}
+
+// Parses the pipe separated output of `ipmitool sensor`
+func parseIpmiSensors(output []byte) map[string]system.IpmiSensor {
+	sensors := make(map[string]system.IpmiSensor)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// Example line: Inlet Temp | 24.000 | degrees C | ok | na | ...
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 4 {
+			continue
+		}
+		name := strings.TrimSpace(fields[0])
+		reading := strings.TrimSpace(fields[1])
+		status := strings.TrimSpace(fields[3])
+		if name == "" || reading == "na" || status == "na" {
+			continue
+		}
+		sensor := system.IpmiSensor{
+			Unit:   strings.TrimSpace(fields[2]),
+			Status: status,
+		}
+		// discrete sensors (PSU, chassis intrusion) report a hex state
+		if value, err := strconv.ParseFloat(reading, 64); err == nil {
+			sensor.Value = twoDecimals(value)
+		} else if value, err := strconv.ParseUint(reading, 0, 64); err == nil {
+			sensor.Value = float64(value)
+		} else {
+			continue
+		}
+		sensors[name] = sensor
+	}
+	return sensors
+}
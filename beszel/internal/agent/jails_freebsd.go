@@ -0,0 +1,62 @@
+//go:build freebsd
+
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum time to wait for jls / rctl
+const jailCmdTimeout = 5 * time.Second
+
+// Returns cpu and memory of running jails. Usage comes from rctl resource
+// accounting, which needs kern.racct.enable=1 set in /boot/loader.conf.
+// Jails are still listed without usage if accounting is disabled.
+func getJailStats(cpuCount int) ([]*container.Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jailCmdTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "jls", "name").Output()
+	if err != nil {
+		return nil, err
+	}
+	var stats []*container.Stats
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" {
+			continue
+		}
+		jail := &container.Stats{Name: name, Type: "jail"}
+		if usage, err := exec.CommandContext(ctx, "rctl", "-u", "jail:"+name).Output(); err == nil {
+			resources := parseRctlUsage(usage)
+			// pcpu is a percent of one cpu, containers use a percent of the host
+			jail.Cpu = smallDecimals(float64(resources["pcpu"]) / float64(max(cpuCount, 1)))
+			jail.Mem = bytesToMegabytes(float64(resources["memoryuse"]))
+			jail.SwapUsed = bytesToMegabytes(float64(resources["swapuse"]))
+		}
+		stats = append(stats, jail)
+	}
+	return stats, nil
+}
+
+// Parses "resource=value" lines from rctl -u
+func parseRctlUsage(output []byte) map[string]uint64 {
+	resources := make(map[string]uint64)
+	for _, line := range strings.Split(string(output), "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+			resources[name] = n
+		}
+	}
+	return resources
+}
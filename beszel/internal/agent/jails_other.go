@@ -0,0 +1,12 @@
+//go:build !freebsd
+
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"errors"
+)
+
+func getJailStats(cpuCount int) ([]*container.Stats, error) {
+	return nil, errors.New("jails are only supported on freebsd")
+}
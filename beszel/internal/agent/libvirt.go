@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum time to wait for virsh to return domain stats
+const virshTimeout = 5 * time.Second
+
+type vmPrevCpu struct {
+	time  uint64 // cpu.time in nanoseconds
+	taken time.Time
+}
+
+// Reports cpu and memory of running libvirt domains using virsh
+type libvirtManager struct {
+	uri      string
+	cpuCount int
+	prevCpu  map[string]vmPrevCpu
+}
+
+// Returns a new libvirtManager, or nil if virsh is not installed
+func newLibvirtManager(cpuCount int) *libvirtManager {
+	if _, err := exec.LookPath("virsh"); err != nil {
+		slog.Debug("libvirt", "err", err)
+		return nil
+	}
+	uri := "qemu:///system"
+	if val, exists := os.LookupEnv("LIBVIRT_URI"); exists && val != "" {
+		uri = val
+	}
+	slog.Info("LIBVIRT", "uri", uri)
+	return &libvirtManager{
+		uri:      uri,
+		cpuCount: max(cpuCount, 1),
+		prevCpu:  make(map[string]vmPrevCpu),
+	}
+}
+
+// Returns stats for all running domains. Cpu is the share of total host cpu
+// since the previous call, so a newly seen domain reports zero cpu.
+func (lm *libvirtManager) getStats() ([]system.VmStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), virshTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "virsh", "-c", lm.uri, "domstats", "--raw", "--state-running", "--cpu-total", "--balloon", "--vcpu").Output()
+	if err != nil {
+		return nil, err
+	}
+	domains := parseDomstats(output)
+
+	now := time.Now()
+	vms := make([]system.VmStats, 0, len(domains))
+	seen := make(map[string]struct{}, len(domains))
+	for name, fields := range domains {
+		seen[name] = struct{}{}
+		vm := system.VmStats{Name: name}
+		vm.Vcpus, _ = strconv.Atoi(fields["vcpu.current"])
+		// rss is the host memory actually used, current is the balloon size
+		memKb, err := strconv.ParseUint(fields["balloon.rss"], 10, 64)
+		if err != nil {
+			memKb, _ = strconv.ParseUint(fields["balloon.current"], 10, 64)
+		}
+		vm.Mem = bytesToMegabytes(float64(memKb * 1024))
+		if cpuTime, err := strconv.ParseUint(fields["cpu.time"], 10, 64); err == nil {
+			if prev, ok := lm.prevCpu[name]; ok {
+				if delta, ok := counterDelta(prev.time, cpuTime); ok {
+					elapsed := float64(now.Sub(prev.taken).Nanoseconds()) * float64(lm.cpuCount)
+					vm.Cpu = twoDecimals(float64(delta) / elapsed * 100)
+				}
+			}
+			lm.prevCpu[name] = vmPrevCpu{time: cpuTime, taken: now}
+		}
+		vms = append(vms, vm)
+	}
+	// forget domains that stopped
+	for name := range lm.prevCpu {
+		if _, ok := seen[name]; !ok {
+			delete(lm.prevCpu, name)
+		}
+	}
+	return vms, nil
+}
+
+// Parses `virsh domstats --raw` output into key=value fields per domain
+func parseDomstats(output []byte) map[string]map[string]string {
+	domains := make(map[string]map[string]string)
+	var current map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Example output:
+		// Domain: 'vm1'
+		//   cpu.time=123456789
+		if name, found := strings.CutPrefix(line, "Domain: "); found {
+			current = make(map[string]string)
+			domains[strings.Trim(name, "'")] = current
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); found && current != nil {
+			current[key] = value
+		}
+	}
+	return domains
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Memory bandwidth monitoring data exposed by the resctrl filesystem (Intel RDT / AMD QoS)
+const resctrlMonData = "/sys/fs/resctrl/mon_data"
+
+type memBandwidthStats struct {
+	totalBytes uint64
+	time       time.Time
+}
+
+// Enables memory bandwidth monitoring if resctrl is mounted
+func (a *Agent) initializeMemBandwidth() {
+	total, err := readMemBandwidthBytes()
+	if err != nil {
+		slog.Warn("Memory bandwidth monitoring unavailable", "err", err)
+		return
+	}
+	a.memBandwidth = &memBandwidthStats{totalBytes: total, time: time.Now()}
+}
+
+// Returns aggregate memory bandwidth in GB/s since the last call
+func (a *Agent) getMemBandwidth() float64 {
+	total, err := readMemBandwidthBytes()
+	if err != nil {
+		slog.Debug("Error reading memory bandwidth", "err", err)
+		return 0
+	}
+	delta, ok := counterDelta(a.memBandwidth.totalBytes, total)
+	secondsElapsed := time.Since(a.memBandwidth.time).Seconds()
+	a.memBandwidth.totalBytes = total
+	a.memBandwidth.time = time.Now()
+	if !ok || secondsElapsed <= 0 {
+		return 0
+	}
+	return bytesToGigabytes(uint64(float64(delta) / secondsElapsed))
+}
+
+// Sums mbm_total_bytes across all L3 monitoring domains
+func readMemBandwidthBytes() (uint64, error) {
+	domains, err := filepath.Glob(filepath.Join(resctrlMonData, "mon_L3_*", "mbm_total_bytes"))
+	if err != nil {
+		return 0, err
+	}
+	if len(domains) == 0 {
+		return 0, errors.New("resctrl not mounted or mbm not supported")
+	}
+	var total uint64
+	for _, path := range domains {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		// value is "Unavailable" if the counter can't be read
+		value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += value
+	}
+	return total, nil
+}
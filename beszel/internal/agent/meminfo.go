@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Splits used memory into anonymous, page cache, and kernel slab memory
+// using /proc/meminfo. No-op where /proc/meminfo doesn't exist.
+func setMemBreakdown(systemStats *system.Stats) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return
+	}
+	meminfo := parseMeminfo(data)
+	systemStats.MemAnon = bytesToGigabytes(meminfo["AnonPages"] * 1024)
+	systemStats.MemPageCache = bytesToGigabytes(meminfo["Cached"] * 1024)
+	systemStats.MemSlab = bytesToGigabytes(meminfo["Slab"] * 1024)
+}
+
+// Parses /proc/meminfo into a map of field name to value in kB
+func parseMeminfo(data []byte) map[string]uint64 {
+	meminfo := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Example line: MemTotal:       16314516 kB
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			meminfo[name] = n
+		}
+	}
+	return meminfo
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directory where named network namespaces are bind mounted by `ip netns`
+const netNsDir = "/var/run/netns"
+
+// Sets up the network namespaces passed in via the NETNS env var.
+// Values may be namespace names (from /var/run/netns) or process IDs.
+func (a *Agent) initializeNetNsStats() {
+	netNs, exists := os.LookupEnv("NETNS")
+	if !exists {
+		return
+	}
+	a.netNsStats = make(map[string]*system.NetIoStats)
+	for _, ns := range strings.Split(netNs, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		stats := &system.NetIoStats{Name: ns}
+		if data, err := readNetNsDev(ns); err == nil {
+			stats.BytesSent, stats.BytesRecv = parseNetDev(data)
+			stats.Time = time.Now()
+			slog.Info("Detected network namespace", "name", ns, "sent", stats.BytesSent, "recv", stats.BytesRecv)
+		} else {
+			slog.Warn("Network namespace unavailable", "name", ns, "err", err)
+		}
+		a.netNsStats[ns] = stats
+	}
+}
+
+// Returns bandwidth usage for each monitored network namespace.
+// Namespaces that can't be read are skipped and picked up again when they return.
+func (a *Agent) getNetNsStats() map[string]system.NetNsStats {
+	nsStats := make(map[string]system.NetNsStats, len(a.netNsStats))
+	for name, prev := range a.netNsStats {
+		data, err := readNetNsDev(name)
+		if err != nil {
+			slog.Debug("Error reading network namespace", "name", name, "err", err)
+			prev.Time = time.Time{}
+			continue
+		}
+		bytesSent, bytesRecv := parseNetDev(data)
+		// first successful read or counters went backwards (namespace recreated)
+		if prev.Time.IsZero() || bytesSent < prev.BytesSent || bytesRecv < prev.BytesRecv {
+			prev.BytesSent, prev.BytesRecv, prev.Time = bytesSent, bytesRecv, time.Now()
+			continue
+		}
+		secondsElapsed := time.Since(prev.Time).Seconds()
+		nsStats[name] = system.NetNsStats{
+			NetworkSent: a.megabytesPerSecond(float64(bytesSent-prev.BytesSent) / secondsElapsed),
+			NetworkRecv: a.megabytesPerSecond(float64(bytesRecv-prev.BytesRecv) / secondsElapsed),
+		}
+		prev.BytesSent, prev.BytesRecv, prev.Time = bytesSent, bytesRecv, time.Now()
+	}
+	return nsStats
+}
+
+// Sums bytes sent and received for all non-loopback interfaces in /proc/net/dev output
+func parseNetDev(data []byte) (bytesSent, bytesRecv uint64) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Example line: eth0: 1234 10 0 0 0 0 0 0 5678 12 0 0 0 0 0 0
+		name, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		recv, _ := strconv.ParseUint(fields[0], 10, 64)
+		sent, _ := strconv.ParseUint(fields[8], 10, 64)
+		bytesRecv += recv
+		bytesSent += sent
+	}
+	return bytesSent, bytesRecv
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns the contents of /proc/net/dev as seen from inside a network namespace.
+// Named namespaces are entered with setns, which requires CAP_SYS_ADMIN.
+func readNetNsDev(ns string) ([]byte, error) {
+	if pid, err := strconv.Atoi(ns); err == nil {
+		return os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "net/dev"))
+	}
+
+	nsFile, err := os.Open(filepath.Join(netNsDir, ns))
+	if err != nil {
+		return nil, err
+	}
+	defer nsFile.Close()
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	resultChan := make(chan result, 1)
+
+	// run in a separate goroutine so the locked thread is discarded
+	// if it can't be switched back to the original namespace
+	go func() {
+		runtime.LockOSThread()
+		origin, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			resultChan <- result{err: err}
+			return
+		}
+		defer origin.Close()
+		if err := unix.Setns(int(nsFile.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			resultChan <- result{err: err}
+			return
+		}
+		data, err := os.ReadFile("/proc/thread-self/net/dev")
+		resultChan <- result{data, err}
+		if unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+
+	res := <-resultChan
+	return res.data, res.err
+}
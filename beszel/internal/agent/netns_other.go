@@ -0,0 +1,9 @@
+//go:build !linux
+
+package agent
+
+import "errors"
+
+func readNetNsDev(ns string) ([]byte, error) {
+	return nil, errors.New("network namespaces are only supported on linux")
+}
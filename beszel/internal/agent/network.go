@@ -1,57 +1,247 @@
 package agent
 
 import (
+	"beszel/internal/entities/system"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	psutilNet "github.com/shirou/gopsutil/v4/net"
 )
 
+// How often to refresh interface link settings and state, which rarely change
+const netInfoInterval = 5 * time.Minute
+
 func (a *Agent) initializeNetIoStats() {
-	// reset valid network interfaces
-	a.netInterfaces = make(map[string]struct{}, 0)
-
-	// map of network interface names passed in via NICS env var
-	var nicsMap map[string]struct{}
-	nics, nicsEnvExists := os.LookupEnv("NICS")
-	if nicsEnvExists {
-		nicsMap = make(map[string]struct{}, 0)
-		for _, nic := range strings.Split(nics, ",") {
-			nicsMap[nic] = struct{}{}
-		}
-	}
+	a.netRefreshTime = time.Now()
 
 	// reset network I/O stats
 	a.netIoStats.BytesSent = 0
 	a.netIoStats.BytesRecv = 0
+	a.netIoStats.Errors = 0
+	a.netIoStats.Drops = 0
 
 	// get intial network I/O stats
+	a.netInterfaces = make(map[string]struct{})
+	a.netIfaceStats = make(map[string]system.NetIoStats)
+	a.nicFilter = newNicFilter()
 	if netIO, err := psutilNet.IOCounters(true); err == nil {
 		a.netIoStats.Time = time.Now()
+		a.nicFilter.warnUnknown(netIO)
+		valid := a.validNetInterfaces(netIO)
 		for _, v := range netIO {
-			switch {
-			// skip if nics exists and the interface is not in the list
-			case nicsEnvExists:
-				if _, nameInNics := nicsMap[v.Name]; !nameInNics {
-					continue
-				}
-			// otherwise run the interface name through the skipNetworkInterface function
-			default:
-				if a.skipNetworkInterface(v) {
-					continue
-				}
+			if _, ok := valid[v.Name]; !ok {
+				continue
 			}
 			slog.Info("Detected network interface", "name", v.Name, "sent", v.BytesSent, "recv", v.BytesRecv)
 			a.netIoStats.BytesSent += v.BytesSent
 			a.netIoStats.BytesRecv += v.BytesRecv
+			a.netIoStats.Errors += v.Errin + v.Errout
+			a.netIoStats.Drops += v.Dropin + v.Dropout
 			// store as a valid network interface
 			a.netInterfaces[v.Name] = struct{}{}
+			a.netIfaceStats[v.Name] = system.NetIoStats{BytesSent: v.BytesSent, BytesRecv: v.BytesRecv, Time: a.netIoStats.Time}
+		}
+	}
+	a.updateNetInterfaceInfo()
+}
+
+// Interface names from the NICS, NICS_INCLUDE, and NICS_EXCLUDE env vars
+type nicFilter struct {
+	only    map[string]struct{} // Only these are monitored (nil if NICS not set)
+	include map[string]struct{} // Monitored even if skipNetworkInterface would skip them
+	exclude map[string]struct{} // Never monitored, overriding include
+}
+
+// Returns the interface names listed in the env var, or nil if it isn't set
+func parseNicList(envVar string) map[string]struct{} {
+	value, exists := os.LookupEnv(envVar)
+	if !exists {
+		return nil
+	}
+	names := make(map[string]struct{})
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+func newNicFilter() nicFilter {
+	return nicFilter{
+		only:    parseNicList("NICS"),
+		include: parseNicList("NICS_INCLUDE"),
+		exclude: parseNicList("NICS_EXCLUDE"),
+	}
+}
+
+// Warns about listed interfaces that don't exist. They are still picked up
+// if they appear later.
+func (nf nicFilter) warnUnknown(netIO []psutilNet.IOCountersStat) {
+	existing := make(map[string]struct{}, len(netIO))
+	for _, v := range netIO {
+		existing[v.Name] = struct{}{}
+	}
+	for envVar, names := range map[string]map[string]struct{}{"NICS": nf.only, "NICS_INCLUDE": nf.include, "NICS_EXCLUDE": nf.exclude} {
+		for name := range names {
+			if _, ok := existing[name]; !ok {
+				slog.Warn("Network interface not found", "name", name, "env", envVar)
+			}
 		}
 	}
 }
 
+// Returns the names of the interfaces to monitor: those listed in NICS if it
+// is set, otherwise those not skipped by skipNetworkInterface plus those in
+// NICS_INCLUDE. Interfaces in NICS_EXCLUDE are always left out.
+func (a *Agent) validNetInterfaces(netIO []psutilNet.IOCountersStat) map[string]struct{} {
+	nf := a.nicFilter
+	valid := make(map[string]struct{})
+	for _, v := range netIO {
+		if _, excluded := nf.exclude[v.Name]; excluded {
+			continue
+		}
+		_, included := nf.include[v.Name]
+		switch {
+		// skip if nics exists and the interface is not in the list
+		case nf.only != nil:
+			if _, nameInNics := nf.only[v.Name]; !nameInNics {
+				continue
+			}
+		// otherwise run the interface name through the skipNetworkInterface function
+		case !included && a.skipNetworkInterface(v):
+			continue
+		}
+		valid[v.Name] = struct{}{}
+	}
+	return valid
+}
+
+// Re-discovers the interfaces to monitor, so ones that come up later (e.g. VPN
+// tunnels) are tracked and ones that disappear are dropped. Interfaces that
+// persist keep their counters. Returns true if the set of interfaces changed.
+func (a *Agent) refreshNetInterfaces(netIO []psutilNet.IOCountersStat) bool {
+	a.netRefreshTime = time.Now()
+	valid := a.validNetInterfaces(netIO)
+	changed := false
+	for name := range valid {
+		if _, ok := a.netInterfaces[name]; !ok {
+			slog.Info("Detected network interface", "name", name)
+			changed = true
+		}
+	}
+	for name := range a.netInterfaces {
+		if _, ok := valid[name]; !ok {
+			slog.Info("Network interface removed", "name", name)
+			changed = true
+		}
+	}
+	a.netInterfaces = valid
+	return changed
+}
+
+// Refreshes the link settings and state of each valid interface. Reads sysfs
+// on Linux and falls back to gopsutil's interface flags elsewhere.
+func (a *Agent) updateNetInterfaceInfo() {
+	a.netInfoTime = time.Now()
+	a.systemInfo.NetInterfaces = nil
+	var fallback map[string]psutilNet.InterfaceStat
+	for name := range a.netInterfaces {
+		details, ok := getNetInterfaceInfo(name)
+		if !ok {
+			if fallback == nil {
+				fallback = make(map[string]psutilNet.InterfaceStat)
+				if interfaces, err := psutilNet.Interfaces(); err == nil {
+					for _, iface := range interfaces {
+						fallback[iface.Name] = iface
+					}
+				}
+			}
+			iface, exists := fallback[name]
+			if !exists {
+				continue
+			}
+			details = system.NetInterface{Mtu: iface.MTU, Up: slices.Contains(iface.Flags, "up")}
+		}
+		if a.systemInfo.NetInterfaces == nil {
+			a.systemInfo.NetInterfaces = make(map[string]system.NetInterface)
+		}
+		a.systemInfo.NetInterfaces[name] = details
+	}
+}
+
+// Returns the send and receive rates of each valid interface since the
+// previous call, and the bytes sent and received by all of them. Each
+// interface keeps its own counters, so wraparound is handled per interface
+// and one that was reset is re-baselined without affecting the others.
+func (a *Agent) getInterfaceStats(netIO []psutilNet.IOCountersStat) (interfaceStats map[string]system.NetIfStats, sentTotal, recvTotal uint64) {
+	now := time.Now()
+	interfaceStats = make(map[string]system.NetIfStats, len(a.netInterfaces))
+	current := make(map[string]system.NetIoStats, len(a.netInterfaces))
+	for _, v := range netIO {
+		if _, exists := a.netInterfaces[v.Name]; !exists {
+			continue
+		}
+		current[v.Name] = system.NetIoStats{BytesSent: v.BytesSent, BytesRecv: v.BytesRecv, Time: now}
+		prev, ok := a.netIfaceStats[v.Name]
+		if !ok {
+			continue
+		}
+		secondsElapsed := now.Sub(prev.Time).Seconds()
+		if secondsElapsed <= 0 {
+			continue
+		}
+		maxDelta := uint64(maxNetBytesPerSecond * secondsElapsed)
+		sentDelta, sentOk := wrappingDelta(prev.BytesSent, v.BytesSent, maxDelta)
+		recvDelta, recvOk := wrappingDelta(prev.BytesRecv, v.BytesRecv, maxDelta)
+		if !sentOk || !recvOk {
+			slog.Debug("Network counter reset", "interface", v.Name, "sent", v.BytesSent, "recv", v.BytesRecv)
+			continue
+		}
+		sentTotal += sentDelta
+		recvTotal += recvDelta
+		interfaceStats[v.Name] = system.NetIfStats{
+			NetworkSent: a.megabytesPerSecond(float64(sentDelta) / secondsElapsed),
+			NetworkRecv: a.megabytesPerSecond(float64(recvDelta) / secondsElapsed),
+		}
+	}
+	a.netIfaceStats = current
+	return interfaceStats, sentTotal, recvTotal
+}
+
+// Reads the interface's MTU, duplex mode, link speed, and state from sysfs.
+// Duplex and speed are left empty for interfaces that don't report them
+// (virtual, wireless, or link down). bool is false if the interface isn't in
+// sysfs (non-Linux).
+func getNetInterfaceInfo(name string) (system.NetInterface, bool) {
+	dir := filepath.Join("/sys/class/net", name)
+	mtu, err := strconv.Atoi(readSysfsString(filepath.Join(dir, "mtu")))
+	if err != nil {
+		return system.NetInterface{}, false
+	}
+	details := system.NetInterface{Mtu: mtu}
+	if duplex := readSysfsString(filepath.Join(dir, "duplex")); duplex == "full" || duplex == "half" {
+		details.Duplex = duplex
+	}
+	// speed is -1 or unreadable when the link is down
+	if speed, err := strconv.Atoi(readSysfsString(filepath.Join(dir, "speed"))); err == nil && speed > 0 {
+		details.Speed = speed
+	}
+	// virtual interfaces such as tunnels often report "unknown" while working
+	switch readSysfsString(filepath.Join(dir, "operstate")) {
+	case "up":
+		details.Up = true
+	case "unknown":
+		details.Up = readSysfsString(filepath.Join(dir, "carrier")) == "1"
+	}
+	return details, true
+}
+
 func (a *Agent) skipNetworkInterface(v psutilNet.IOCountersStat) bool {
 	switch {
 	case strings.HasPrefix(v.Name, "lo"),
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Tracks out of memory kills from container inspect results. Kills are kept
+// until the hub has them because a killed container is usually restarted,
+// which resets its stats before the next update. Like events, other consumers
+// (Prometheus, HTTP, INTERVAL collections) see them without clearing them.
+type oomTracker struct {
+	pending  map[string]int       // Kills not yet sent to the hub, by container id
+	included map[string]int       // Kills included in the latest collection, by container id
+	names    map[string]string    // Names of containers with pending kills, by container id
+	finished map[string]time.Time // Exit time from the last inspect, by container id
+	mutex    sync.Mutex
+}
+
+// Records the container's last exit. Each exit is counted at most once. The first
+// exit seen is only a baseline unless the container was just seen stopping.
+func (ot *oomTracker) record(id, name string, info *container.ApiInspect, stopped bool) {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+	if ot.finished == nil {
+		ot.finished = make(map[string]time.Time)
+		ot.pending = make(map[string]int)
+		ot.included = make(map[string]int)
+		ot.names = make(map[string]string)
+	}
+	prev, seen := ot.finished[id]
+	if seen && prev.Equal(info.State.FinishedAt) {
+		return
+	}
+	ot.finished[id] = info.State.FinishedAt
+	if info.State.OOMKilled && (seen || stopped) {
+		ot.pending[id]++
+		ot.names[id] = name
+		slog.Warn("Container killed for running out of memory", "id", id)
+	}
+}
+
+// Returns the kills for the container not yet sent to the hub and notes them
+// as included in the current collection
+func (ot *oomTracker) peek(id string) int {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+	kills := ot.pending[id]
+	if kills > 0 {
+		ot.included[id] = kills
+	} else {
+		delete(ot.included, id)
+	}
+	return kills
+}
+
+// Clears the kills included in the latest collection once it has been sent
+// to the hub. Kills recorded since that collection stay pending.
+func (ot *oomTracker) ack() {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+	for id, kills := range ot.included {
+		ot.pending[id] -= kills
+		if ot.pending[id] <= 0 {
+			delete(ot.pending, id)
+			delete(ot.names, id)
+		}
+		delete(ot.included, id)
+	}
+}
+
+// Returns minimal stats carrying the pending kills of containers that aren't
+// running (e.g. stopped with restart: no, or already removed), since they have
+// no other entry to report them with. The kills are included in the collection.
+func (ot *oomTracker) notRunning(running map[string]struct{}) []*container.Stats {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+	var stats []*container.Stats
+	for id, kills := range ot.pending {
+		if _, ok := running[id]; ok {
+			continue
+		}
+		ot.included[id] = kills
+		stats = append(stats, &container.Stats{Name: ot.names[id], OomKills: kills})
+	}
+	return stats
+}
+
+// Removes containers that no longer exist. Unreported kills are kept until
+// they have been sent to the hub.
+func (ot *oomTracker) prune(containers []container.ApiInfo) {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+	if len(ot.finished) == 0 {
+		return
+	}
+	listed := make(map[string]struct{}, len(containers))
+	for _, ctr := range containers {
+		listed[ctr.Id[:12]] = struct{}{}
+	}
+	for id := range ot.finished {
+		if _, ok := listed[id]; !ok {
+			delete(ot.finished, id)
+		}
+	}
+}
+
+// Checks a stopped or restarting container for an out of memory kill if it was
+// running at the last update, bypassing the inspect cache
+func (dm *dockerManager) checkStopped(ctr container.ApiInfo) {
+	id := ctr.Id[:12]
+	dm.containerStatsMutex.RLock()
+	_, wasRunning := dm.containerStatsMap[id]
+	dm.containerStatsMutex.RUnlock()
+	if !wasRunning {
+		return
+	}
+	dm.deleteInspectSync(id)
+	info, err := dm.inspectContainer(id)
+	if err != nil {
+		slog.Debug("Error inspecting container", "id", id, "err", err)
+		return
+	}
+	dm.oom.record(id, ctr.Names[0][1:], info, true)
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"testing"
+	"time"
+)
+
+func TestOomTrackerKeepsKillsUntilAck(t *testing.T) {
+	var ot oomTracker
+	kill := func(finished time.Time) {
+		info := &container.ApiInspect{}
+		info.State.OOMKilled = true
+		info.State.FinishedAt = finished
+		ot.record("abc", "web", info, true)
+	}
+	start := time.Now()
+	kill(start)
+
+	// collections for other consumers don't clear the kill
+	for range 3 {
+		if got := ot.peek("abc"); got != 1 {
+			t.Fatalf("peek = %d; want 1", got)
+		}
+	}
+
+	// a kill after the hub's collection stays pending after the ack
+	kill(start.Add(time.Second))
+	ot.ack()
+	if got := ot.peek("abc"); got != 1 {
+		t.Fatalf("peek after ack = %d; want 1", got)
+	}
+	ot.ack()
+	if got := ot.peek("abc"); got != 0 {
+		t.Fatalf("peek after second ack = %d; want 0", got)
+	}
+
+	// the same exit is only counted once
+	kill(start.Add(time.Second))
+	if got := ot.peek("abc"); got != 0 {
+		t.Fatalf("peek after repeated exit = %d; want 0", got)
+	}
+}
+
+func TestOomTrackerReportsStoppedContainers(t *testing.T) {
+	var ot oomTracker
+	info := &container.ApiInspect{}
+	info.State.OOMKilled = true
+	info.State.FinishedAt = time.Now()
+	ot.record("abc", "web", info, true)
+
+	// the container was removed before the hub's collection
+	ot.prune(nil)
+	stats := ot.notRunning(map[string]struct{}{})
+	if len(stats) != 1 || stats[0].Name != "web" || stats[0].OomKills != 1 {
+		t.Fatalf("notRunning = %+v; want one entry for web with 1 kill", stats)
+	}
+	ot.ack()
+	if stats := ot.notRunning(map[string]struct{}{}); len(stats) != 0 {
+		t.Fatalf("notRunning after ack = %+v; want none", stats)
+	}
+
+	// running containers report their kills through peek
+	ot.record("def", "db", info, true)
+	if stats := ot.notRunning(map[string]struct{}{"def": {}}); len(stats) != 0 {
+		t.Fatalf("notRunning for running container = %+v; want none", stats)
+	}
+}
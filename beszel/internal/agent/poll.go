@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Default time without a request from the hub before a warning is logged
+const defaultPollWarnAfter = 5 * time.Minute
+
+// Tracks when the hub last requested stats, so an agent that shows as down
+// in the hub can tell whether requests are reaching it at all
+type pollTracker struct {
+	started  time.Time
+	lastPoll atomic.Int64 // unix nanoseconds, zero if never polled
+}
+
+func newPollTracker() *pollTracker {
+	return &pollTracker{started: time.Now()}
+}
+
+// Records a served request
+func (pt *pollTracker) record() {
+	pt.lastPoll.Store(time.Now().UnixNano())
+}
+
+// Returns the time of the last served request and whether there has been one
+func (pt *pollTracker) last() (time.Time, bool) {
+	nanos := pt.lastPoll.Load()
+	if nanos == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// Logs a warning when no request has been served within POLL_WARN_AFTER
+// (default 5m, 0 disables), and again when requests resume
+func (pt *pollTracker) watch() {
+	warnAfter := defaultPollWarnAfter
+	if val, exists := os.LookupEnv("POLL_WARN_AFTER"); exists {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			slog.Warn("Invalid POLL_WARN_AFTER", "err", err)
+		} else {
+			warnAfter = d
+		}
+	}
+	if warnAfter <= 0 {
+		return
+	}
+	go func() {
+		warned := false
+		for range time.Tick(min(warnAfter, time.Minute)) {
+			last, polled := pt.last()
+			since := time.Since(last)
+			if !polled {
+				since = time.Since(pt.started)
+			}
+			switch {
+			case since > warnAfter && !warned:
+				warned = true
+				if polled {
+					slog.Warn("No requests from hub", "last", last.Format(time.RFC3339), "ago", since.Round(time.Second))
+				} else {
+					slog.Warn("No requests from hub since agent started", "ago", since.Round(time.Second))
+				}
+			case since <= warnAfter && warned:
+				warned = false
+				slog.Info("Requests from hub resumed")
+			}
+		}
+	}()
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"path/filepath"
+	"strconv"
+)
+
+const raplPackageDir = "/sys/class/powercap/intel-rapl:0"
+
+// Reads the active RAPL package power limits (PL1 / PL2) in watts.
+// Returns nil where powercap isn't exposed or limits aren't enforced.
+func getPowerCap() *system.PowerCap {
+	if readSysfsString(filepath.Join(raplPackageDir, "enabled")) == "0" {
+		return nil
+	}
+	var powerCap system.PowerCap
+	constraints, _ := filepath.Glob(filepath.Join(raplPackageDir, "constraint_[0-9]*_name"))
+	for _, nameFile := range constraints {
+		prefix := nameFile[:len(nameFile)-len("name")]
+		microwatts, err := strconv.ParseUint(readSysfsString(prefix+"power_limit_uw"), 10, 64)
+		if err != nil || microwatts == 0 {
+			continue
+		}
+		watts := twoDecimals(float64(microwatts) / 1e6)
+		switch readSysfsString(nameFile) {
+		case "long_term":
+			powerCap.LongTerm = watts
+		case "short_term":
+			powerCap.ShortTerm = watts
+		}
+	}
+	if powerCap.LongTerm == 0 && powerCap.ShortTerm == 0 {
+		return nil
+	}
+	return &powerCap
+}
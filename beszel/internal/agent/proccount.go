@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Sets process, thread, and zombie counts. On Linux this reads /proc/loadavg
+// and each process's stat file, which is much cheaper than full process stats.
+// Elsewhere only the process count is set.
+func setProcessCounts(systemStats *system.Stats) {
+	// procs_total in /proc/loadavg counts every task, so it's the thread count
+	if misc, err := load.Misc(); err == nil {
+		systemStats.ThreadCount = uint32(misc.ProcsTotal)
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		if pids, err := process.Pids(); err == nil {
+			systemStats.ProcessCount = uint32(len(pids))
+		}
+		return
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		systemStats.ProcessCount++
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// state follows the parenthesized command name, which may contain spaces
+		if i := bytes.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) && stat[i+2] == 'Z' {
+			systemStats.ZombieCount++
+		}
+	}
+}
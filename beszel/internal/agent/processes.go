@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"cmp"
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// How often to sample processes (enumerating processes is expensive)
+const processStatsInterval = 30 * time.Second
+
+type processStatsManager struct {
+	topN      int                   // Number of processes to report by cpu and by memory
+	cpuCount  int                   // Logical cpus, used to scale cpu percent to the whole host
+	prevCpu   map[int32]float64     // Cpu seconds for each pid at the last sample
+	prevTime  time.Time             // Time of the last sample
+	processes []system.ProcessStats // Latest top processes
+	updated   time.Time             // Time of the last successful collection
+	mutex     sync.Mutex
+}
+
+// Returns a new processStatsManager and starts collecting in the background
+func newProcessStatsManager(ctx context.Context, cpuCount int) *processStatsManager {
+	topN := 5
+	if n, err := strconv.Atoi(os.Getenv("TOP_PROCESSES")); err == nil && n > 0 {
+		topN = n
+	}
+	pm := &processStatsManager{
+		topN:     topN,
+		cpuCount: max(cpuCount, 1),
+	}
+	go pm.startCollector(ctx)
+	return pm
+}
+
+// Refreshes process stats on an interval
+func (pm *processStatsManager) startCollector(ctx context.Context) {
+	for {
+		processes, err := pm.collect()
+		if err != nil {
+			slog.Warn("Error getting process stats", "err", err)
+		} else {
+			pm.mutex.Lock()
+			pm.processes = processes
+			pm.updated = time.Now()
+			pm.mutex.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(processStatsInterval):
+		}
+	}
+}
+
+// Returns the latest top processes
+func (pm *processStatsManager) getProcesses() []system.ProcessStats {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return slices.Clone(pm.processes)
+}
+
+// Returns the time of the last successful collection
+func (pm *processStatsManager) lastUpdate() time.Time {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return pm.updated
+}
+
+// Reads cpu and memory of all processes and returns the top processes.
+// Cpu is the share of total host cpu since the previous sample, so the first run reports zero cpu.
+func (pm *processStatsManager) collect() ([]system.ProcessStats, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	secondsElapsed := time.Since(pm.prevTime).Seconds()
+	firstRun := pm.prevTime.IsZero()
+	pm.prevTime = time.Now()
+
+	cpuTimes := make(map[int32]float64, len(procs))
+	processes := make([]system.ProcessStats, 0, len(procs))
+	for _, p := range procs {
+		name, err := p.Name()
+		if err != nil {
+			continue
+		}
+		stats := system.ProcessStats{Name: name, Pid: p.Pid}
+		if mem, err := p.MemoryInfo(); err == nil {
+			stats.Mem = bytesToMegabytes(float64(mem.RSS))
+		}
+		if times, err := p.Times(); err == nil {
+			total := times.User + times.System
+			cpuTimes[p.Pid] = total
+			// only count cpu used since the last sample by processes that existed then
+			if prev, ok := pm.prevCpu[p.Pid]; ok && !firstRun && secondsElapsed > 0 && total >= prev {
+				stats.Cpu = twoDecimals((total - prev) / secondsElapsed / float64(pm.cpuCount) * 100)
+			}
+		}
+		processes = append(processes, stats)
+	}
+	pm.prevCpu = cpuTimes
+	return topProcesses(processes, pm.topN), nil
+}
+
+// Returns processes in the top n by cpu or by memory, sorted by cpu
+func topProcesses(processes []system.ProcessStats, n int) []system.ProcessStats {
+	if len(processes) <= n {
+		slices.SortFunc(processes, func(a, b system.ProcessStats) int { return cmp.Compare(b.Cpu, a.Cpu) })
+		return processes
+	}
+	top := make(map[int32]struct{}, n*2)
+	slices.SortFunc(processes, func(a, b system.ProcessStats) int { return cmp.Compare(b.Mem, a.Mem) })
+	for _, p := range processes[:n] {
+		top[p.Pid] = struct{}{}
+	}
+	slices.SortFunc(processes, func(a, b system.ProcessStats) int { return cmp.Compare(b.Cpu, a.Cpu) })
+	for _, p := range processes[:n] {
+		top[p.Pid] = struct{}{}
+	}
+	result := make([]system.ProcessStats, 0, len(top))
+	for _, p := range processes {
+		if _, ok := top[p.Pid]; ok {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Serves the stats in Prometheus text format on /metrics if PROMETHEUS_PORT is
+// set. The value may be a port or a host:port address.
+func (a *Agent) startPrometheusServer() {
+	addr, exists := os.LookupEnv("PROMETHEUS_PORT")
+	if !exists || addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", requireToken(a.handleMetrics))
+	a.httpServers = append(a.httpServers, listenHttp("Prometheus", addr, mux))
+}
+
+func (a *Agent) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	// events are left for the hub, which is the only consumer that drains them
+	data := a.collectStats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(prometheusMetrics(&data))
+}
+
+// Writes metric families in the Prometheus text exposition format
+type promWriter struct {
+	b strings.Builder
+}
+
+// Writes the HELP and TYPE lines of a gauge
+func (pw *promWriter) gauge(name, help string) {
+	fmt.Fprintf(&pw.b, "# HELP beszel_%s %s\n# TYPE beszel_%s gauge\n", name, help, name)
+}
+
+// Writes a sample. labels are name, value pairs.
+func (pw *promWriter) sample(name string, value float64, labels ...string) {
+	pw.b.WriteString("beszel_" + name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		pw.b.WriteString(sep + labels[i] + `="` + promEscaper.Replace(labels[i+1]) + `"`)
+	}
+	if len(labels) > 1 {
+		pw.b.WriteByte('}')
+	}
+	pw.b.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+// Writes a gauge with a single unlabeled sample
+func (pw *promWriter) single(name, help string, value float64) {
+	pw.gauge(name, help)
+	pw.sample(name, value)
+}
+
+// Escapes label values as required by the text format
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Returns the keys of a map in sorted order so output is stable between scrapes
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Returns the stats in the Prometheus text exposition format. Sizes are
+// converted to bytes and rates to bytes per second.
+func prometheusMetrics(data *system.CombinedData) []byte {
+	const mb, gb = 1 << 20, 1 << 30
+	stats := &data.Stats
+	var pw promWriter
+
+	pw.single("cpu_usage_percent", "Host cpu usage.", stats.Cpu)
+	pw.single("cpu_iowait_percent", "Percent of cpu time waiting on I/O.", stats.CpuIowait)
+	pw.single("cpu_steal_percent", "Percent of cpu time taken by the hypervisor.", stats.CpuSteal)
+	pw.single("load1", "1 minute load average.", stats.LoadAvg1)
+	pw.single("load5", "5 minute load average.", stats.LoadAvg5)
+	pw.single("load15", "15 minute load average.", stats.LoadAvg15)
+	pw.single("memory_total_bytes", "Total memory.", stats.Mem*gb)
+	pw.single("memory_used_bytes", "Used memory.", stats.MemUsed*gb)
+	pw.single("memory_used_percent", "Used memory percent.", stats.MemPct)
+	pw.single("memory_buffcache_bytes", "Memory used by buffers and cache.", stats.MemBuffCache*gb)
+	if stats.MemZfsArc > 0 {
+		pw.single("memory_zfs_arc_bytes", "Memory used by the ZFS ARC, not included in used memory.", stats.MemZfsArc*gb)
+	}
+	pw.single("swap_total_bytes", "Total swap.", stats.Swap*gb)
+	pw.single("swap_used_bytes", "Used swap.", stats.SwapUsed*gb)
+	pw.single("swap_in_bytes_per_second", "Bytes read from swap per second.", stats.SwapInPs*mb)
+	pw.single("swap_out_bytes_per_second", "Bytes written to swap per second.", stats.SwapOutPs*mb)
+	pw.single("network_transmit_bytes_per_second", "Bytes sent per second on all interfaces.", stats.NetworkSent*mb)
+	pw.single("network_receive_bytes_per_second", "Bytes received per second on all interfaces.", stats.NetworkRecv*mb)
+	pw.single("uptime_seconds", "Host uptime.", float64(data.Info.Uptime))
+
+	if p := stats.Pressure; p != nil {
+		pw.gauge("pressure_some_percent", "Percent of the last 10 seconds some tasks were stalled on the resource.")
+		pw.sample("pressure_some_percent", p.CpuSome, "resource", "cpu")
+		pw.sample("pressure_some_percent", p.MemSome, "resource", "memory")
+		pw.sample("pressure_some_percent", p.IoSome, "resource", "io")
+		pw.gauge("pressure_full_percent", "Percent of the last 10 seconds all non-idle tasks were stalled on the resource.")
+		pw.sample("pressure_full_percent", p.CpuFull, "resource", "cpu")
+		pw.sample("pressure_full_percent", p.MemFull, "resource", "memory")
+		pw.sample("pressure_full_percent", p.IoFull, "resource", "io")
+	}
+
+	// root filesystem is labeled "/" like the flattened stats
+	filesystems := map[string]system.FsStats{"/": {
+		DiskTotal:   stats.DiskTotal,
+		DiskUsed:    stats.DiskUsed,
+		DiskReadPs:  stats.DiskReadPs,
+		DiskWritePs: stats.DiskWritePs,
+	}}
+	for name, fs := range stats.ExtraFs {
+		filesystems[name] = *fs
+	}
+	fsNames := sortedKeys(filesystems)
+	pw.gauge("filesystem_size_bytes", "Filesystem size.")
+	for _, name := range fsNames {
+		pw.sample("filesystem_size_bytes", filesystems[name].DiskTotal*gb, "filesystem", name)
+	}
+	pw.gauge("filesystem_used_bytes", "Used filesystem space.")
+	for _, name := range fsNames {
+		pw.sample("filesystem_used_bytes", filesystems[name].DiskUsed*gb, "filesystem", name)
+	}
+	pw.gauge("filesystem_read_bytes_per_second", "Bytes read per second from the filesystem's disk.")
+	for _, name := range fsNames {
+		pw.sample("filesystem_read_bytes_per_second", filesystems[name].DiskReadPs*mb, "filesystem", name)
+	}
+	pw.gauge("filesystem_write_bytes_per_second", "Bytes written per second to the filesystem's disk.")
+	for _, name := range fsNames {
+		pw.sample("filesystem_write_bytes_per_second", filesystems[name].DiskWritePs*mb, "filesystem", name)
+	}
+
+	if len(stats.Interfaces) > 0 {
+		names := sortedKeys(stats.Interfaces)
+		pw.gauge("interface_transmit_bytes_per_second", "Bytes sent per second on the interface.")
+		for _, name := range names {
+			pw.sample("interface_transmit_bytes_per_second", stats.Interfaces[name].NetworkSent*mb, "interface", name)
+		}
+		pw.gauge("interface_receive_bytes_per_second", "Bytes received per second on the interface.")
+		for _, name := range names {
+			pw.sample("interface_receive_bytes_per_second", stats.Interfaces[name].NetworkRecv*mb, "interface", name)
+		}
+	}
+
+	if len(stats.Temperatures) > 0 {
+		pw.gauge("temperature_celsius", "Sensor temperature.")
+		for _, name := range sortedKeys(stats.Temperatures) {
+			pw.sample("temperature_celsius", stats.Temperatures[name], "sensor", name)
+		}
+	}
+
+	if len(data.Containers) > 0 {
+		containers := slices.Clone(data.Containers)
+		slices.SortFunc(containers, func(x, y *container.Stats) int { return strings.Compare(x.Name, y.Name) })
+		families := []struct {
+			name, help string
+			value      func(*container.Stats) float64
+		}{
+			{"container_cpu_usage_percent", "Container cpu usage as a percent of the host.", func(c *container.Stats) float64 { return c.Cpu }},
+			{"container_memory_used_bytes", "Container memory usage.", func(c *container.Stats) float64 { return c.Mem * mb }},
+			{"container_memory_limit_bytes", "Container memory limit, zero if unlimited.", func(c *container.Stats) float64 { return c.MemLimit * mb }},
+			{"container_network_transmit_bytes_per_second", "Bytes sent per second by the container.", func(c *container.Stats) float64 { return c.NetworkSent * mb }},
+			{"container_network_receive_bytes_per_second", "Bytes received per second by the container.", func(c *container.Stats) float64 { return c.NetworkRecv * mb }},
+			{"container_disk_read_bytes_per_second", "Bytes read per second by the container.", func(c *container.Stats) float64 { return c.DiskReadPs * mb }},
+			{"container_disk_write_bytes_per_second", "Bytes written per second by the container.", func(c *container.Stats) float64 { return c.DiskWritePs * mb }},
+			{"container_restarts", "Container restarts by the restart policy.", func(c *container.Stats) float64 { return float64(c.Restarts) }},
+			{"container_uptime_seconds", "Time since the container started.", func(c *container.Stats) float64 { return float64(c.Uptime) }},
+		}
+		for _, family := range families {
+			pw.gauge(family.name, family.help)
+			for _, ctr := range containers {
+				pw.sample(family.name, family.value(ctr), "container", ctr.Name)
+			}
+		}
+	}
+
+	return []byte(pw.b.String())
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Returns the "some" and "full" avg10 values from /proc/pressure/<resource>:
+// the percent of the last 10 seconds in which at least one (some) or all
+// (full) non-idle tasks were stalled on the resource. bool is false if PSI
+// isn't available (non-Linux, kernel < 4.20, or disabled with psi=0).
+func readPressure(resource string) (some, full float64, ok bool) {
+	data, err := os.ReadFile("/proc/pressure/" + resource)
+	if err != nil {
+		return 0, 0, false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Example line: full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, found := strings.CutPrefix(fields[1], "avg10=")
+		if !found {
+			continue
+		}
+		avg10, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "some":
+			some, ok = avg10, true
+		case "full":
+			full, ok = avg10, true
+		}
+	}
+	return some, full, ok
+}
+
+// Sets cpu, memory, and io pressure if the kernel exposes PSI
+func (a *Agent) setPressureStats(systemStats *system.Stats) {
+	if !a.psi || !a.collectorEnabled("psi") {
+		return
+	}
+	var pressure system.Pressure
+	var found bool
+	if some, full, ok := readPressure("cpu"); ok {
+		// full is only reported at the system level since Linux 5.13
+		pressure.CpuSome, pressure.CpuFull = twoDecimals(some), twoDecimals(full)
+		found = true
+	}
+	if some, full, ok := readPressure("memory"); ok {
+		pressure.MemSome, pressure.MemFull = twoDecimals(some), twoDecimals(full)
+		found = true
+	}
+	if some, full, ok := readPressure("io"); ok {
+		pressure.IoSome, pressure.IoFull = twoDecimals(some), twoDecimals(full)
+		found = true
+	}
+	if found {
+		systemStats.Pressure = &pressure
+	}
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPublicIpUrl = "https://api.ipify.org"
+	publicIpInterval   = 6 * time.Hour
+	publicIpRetry      = 5 * time.Minute
+)
+
+// Periodically looks up the host's public IP from an external service
+type publicIpManager struct {
+	url    string
+	client *http.Client
+	ip     string
+	mutex  sync.Mutex
+}
+
+// Returns a new publicIpManager and starts looking up the IP in the background
+func newPublicIpManager(ctx context.Context) *publicIpManager {
+	url := defaultPublicIpUrl
+	if val, exists := os.LookupEnv("PUBLIC_IP_URL"); exists && val != "" {
+		url = val
+	}
+	slog.Info("PUBLIC_IP", "url", url)
+	pm := &publicIpManager{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+	go func() {
+		for {
+			ip, err := pm.lookup()
+			if err != nil {
+				slog.Warn("Error getting public IP", "err", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(publicIpRetry):
+				}
+				continue
+			}
+			pm.mutex.Lock()
+			pm.ip = ip
+			pm.mutex.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(publicIpInterval):
+			}
+		}
+	}()
+	return pm
+}
+
+// Returns the last known public IP, or an empty string if not known yet
+func (pm *publicIpManager) getIp() string {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return pm.ip
+}
+
+// Requests the IP from the service, which must respond with the plain text address
+func (pm *publicIpManager) lookup() (string, error) {
+	resp, err := pm.client.Get(pm.url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := strings.TrimSpace(string(body))
+	if net.ParseIP(ip) == nil {
+		return "", errors.New("invalid response: " + ip)
+	}
+	return ip, nil
+}
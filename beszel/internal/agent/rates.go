@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+)
+
+// Computes rates over a fixed trailing window instead of since the previous
+// sample, so values don't depend on how often the hub polls. Each counter
+// keeps only the samples inside the window plus one baseline before it, so
+// memory use is a few dozen bytes per counter per sample in the window.
+type rateWindow struct {
+	window  time.Duration
+	history map[string][]rateSample
+	mutex   sync.Mutex
+}
+
+type rateSample struct {
+	time   time.Time
+	values []uint64
+}
+
+// Returns a new rateWindow using RATE_WINDOW, or nil if it's not set
+func newRateWindow() *rateWindow {
+	val, exists := os.LookupEnv("RATE_WINDOW")
+	if !exists {
+		return nil
+	}
+	window, err := time.ParseDuration(val)
+	if err != nil || window <= 0 {
+		slog.Warn("Invalid RATE_WINDOW", "value", val)
+		return nil
+	}
+	slog.Info("RATE_WINDOW", "window", window)
+	return &rateWindow{window: window, history: make(map[string][]rateSample)}
+}
+
+// Records the current values of the counters identified by key and returns
+// how much each increased since the start of the window, along with the
+// seconds elapsed. ok is false if the window is disabled, there is no earlier
+// sample, or a counter went backwards, in which case callers should fall back
+// to their previous sample.
+func (rw *rateWindow) deltas(key string, values ...uint64) (deltas []uint64, seconds float64, ok bool) {
+	if rw == nil {
+		return nil, 0, false
+	}
+	now := time.Now()
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	samples := append(rw.history[key], rateSample{time: now, values: values})
+	// drop samples that are older than the newest one at or before the window start
+	cutoff := now.Add(-rw.window)
+	for len(samples) > 2 && !samples[1].time.After(cutoff) {
+		samples = samples[1:]
+	}
+	rw.history[key] = samples
+	if len(samples) < 2 {
+		return nil, 0, false
+	}
+
+	base := samples[0]
+	if len(base.values) != len(values) {
+		rw.history[key] = samples[len(samples)-1:]
+		return nil, 0, false
+	}
+	deltas = make([]uint64, len(values))
+	for i := range values {
+		if deltas[i], ok = counterDelta(base.values[i], values[i]); !ok {
+			// counter reset, start over from the current sample
+			rw.history[key] = samples[len(samples)-1:]
+			return nil, 0, false
+		}
+	}
+	return deltas, now.Sub(base.time).Seconds(), true
+}
+
+// Removes counters that haven't been sampled recently (e.g. removed containers)
+func (rw *rateWindow) prune() {
+	if rw == nil {
+		return
+	}
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+	cutoff := time.Now().Add(-2*rw.window - 10*time.Minute)
+	for key, samples := range rw.history {
+		if samples[len(samples)-1].time.Before(cutoff) {
+			delete(rw.history, key)
+		}
+	}
+}
+
+// Returns host cpu percent over the rate window. ok is false if the window
+// is disabled or doesn't have enough samples yet.
+func (a *Agent) windowedCpuPercent() (float64, bool) {
+	if a.rateWindow == nil {
+		return 0, false
+	}
+	times, err := cpu.Times(false)
+	if err != nil || len(times) == 0 {
+		return 0, false
+	}
+	t := times[0]
+	total := cpuTimesTotal(t)
+	busy := total - t.Idle - t.Iowait
+	// counters are kept in milliseconds to fit the integer history
+	deltas, _, ok := a.rateWindow.deltas("cpu", uint64(total*1000), uint64(busy*1000))
+	if !ok || deltas[0] == 0 {
+		return 0, false
+	}
+	return min(float64(deltas[1])/float64(deltas[0])*100, 100), true
+}
@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Fields that can be listed in the REDACT env var and the actions each allows.
+// Rules only replace or clear existing values; they can't add fields or change types.
+var redactFields = map[string][]string{
+	"hostname":   {"hash", "drop"},
+	"kernel":     {"drop"},
+	"cpumodel":   {"drop"},
+	"publicip":   {"drop"},
+	"containers": {"hash"},
+	"labels":     {"drop"},
+	"vms":        {"hash"},
+	"users":      {"hash"},
+	"processes":  {"hash"},
+}
+
+// Redaction rules applied to the stats before they leave the host
+type redactor struct {
+	rules map[string]string // field -> action
+	salt  string
+}
+
+// Parses the REDACT env var, a comma separated list of field=action pairs
+// (e.g. hostname=hash,containers=hash). Returns nil if no valid rules are set.
+func newRedactor() *redactor {
+	value, exists := os.LookupEnv("REDACT")
+	if !exists {
+		return nil
+	}
+	r := &redactor{rules: make(map[string]string)}
+	r.salt, _ = os.LookupEnv("REDACT_SALT")
+	for _, rule := range strings.Split(value, ",") {
+		field, action, _ := strings.Cut(strings.ToLower(strings.TrimSpace(rule)), "=")
+		if field == "" {
+			continue
+		}
+		if !slices.Contains(redactFields[field], action) {
+			slog.Warn("Invalid REDACT rule", "rule", rule)
+			continue
+		}
+		r.rules[field] = action
+	}
+	if len(r.rules) == 0 {
+		return nil
+	}
+	slog.Info("REDACT", "rules", r.rules)
+	return r
+}
+
+// Returns a short stable pseudonym for value
+func (r *redactor) hash(value string) string {
+	sum := sha256.Sum256([]byte(r.salt + value))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Applies the rules to data. Slices shared with the collectors are copied
+// before being modified.
+func (r *redactor) apply(data *system.CombinedData) {
+	info := &data.Info
+	switch r.rules["hostname"] {
+	case "hash":
+		info.Hostname = r.hash(info.Hostname)
+	case "drop":
+		info.Hostname = ""
+	}
+	if r.rules["kernel"] == "drop" {
+		info.KernelVersion = ""
+	}
+	if r.rules["cpumodel"] == "drop" {
+		info.CpuModel = ""
+	}
+	if r.rules["publicip"] == "drop" {
+		info.PublicIP = ""
+	}
+
+	hashContainers := r.rules["containers"] == "hash"
+	dropLabels := r.rules["labels"] == "drop"
+	if hashContainers || dropLabels {
+		containers := make([]*container.Stats, len(data.Containers))
+		for i, ctr := range data.Containers {
+			redacted := *ctr
+			if hashContainers {
+				redacted.Name = r.hash(ctr.Name)
+			}
+			if dropLabels {
+				redacted.Labels = nil
+			}
+			containers[i] = &redacted
+		}
+		data.Containers = containers
+	}
+	if r.rules["vms"] == "hash" {
+		data.VMs = slices.Clone(data.VMs)
+		for i := range data.VMs {
+			data.VMs[i].Name = r.hash(data.VMs[i].Name)
+		}
+	}
+	if r.rules["users"] == "hash" {
+		info.Users = slices.Clone(info.Users)
+		for i := range info.Users {
+			info.Users[i].Name = r.hash(info.Users[i].Name)
+		}
+	}
+	if r.rules["processes"] == "hash" {
+		info.Processes = slices.Clone(info.Processes)
+		for i := range info.Processes {
+			info.Processes[i].Name = r.hash(info.Processes[i].Name)
+		}
+	}
+}
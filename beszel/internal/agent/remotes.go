@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Maximum time to wait for a remote agent to return its stats
+const remoteTimeout = 10 * time.Second
+
+// Relays stats from remote agents that the hub can't reach directly.
+//
+// Remotes are configured with REMOTES as comma separated name=host:port pairs
+// and authenticated with the private key in REMOTES_KEY_FILE. The hub requests
+// a remote by running the SSH command "remote <name>", or all remotes with
+// "remotes". Each reply is a system.RemoteData tagged with the remote's name.
+//
+// Hosts that can't run the agent can be listed in SSH_TARGETS instead. They
+// are relayed the same way, but their stats are collected by running shell
+// commands over SSH (see sshTarget).
+type remoteManager struct {
+	config  *ssh.ClientConfig
+	addrs   map[string]string // remote name -> address
+	clients map[string]*ssh.Client
+	targets map[string]*sshTarget // agentless hosts
+	mutex   sync.Mutex
+}
+
+// Returns a new remoteManager, or nil if neither REMOTES nor SSH_TARGETS is set
+func newRemoteManager() *remoteManager {
+	remotes, _ := os.LookupEnv("REMOTES")
+	targets, _ := os.LookupEnv("SSH_TARGETS")
+	if remotes == "" && targets == "" {
+		return nil
+	}
+	keyFile, _ := os.LookupEnv("REMOTES_KEY_FILE")
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		slog.Error("REMOTES and SSH_TARGETS require a readable REMOTES_KEY_FILE", "err", err)
+		return nil
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		slog.Error("Invalid REMOTES_KEY_FILE", "err", err)
+		return nil
+	}
+	rm := &remoteManager{
+		config: &ssh.ClientConfig{
+			User:            "u",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         4 * time.Second,
+		},
+		addrs:   make(map[string]string),
+		clients: make(map[string]*ssh.Client),
+		targets: make(map[string]*sshTarget),
+	}
+	if remotes != "" {
+		for _, remote := range strings.Split(remotes, ",") {
+			name, addr, found := strings.Cut(strings.TrimSpace(remote), "=")
+			if !found || name == "" || addr == "" {
+				slog.Warn("Invalid remote", "value", remote)
+				continue
+			}
+			rm.addrs[name] = addr
+		}
+		slog.Info("REMOTES", "remotes", rm.addrs)
+	}
+	if targets != "" {
+		for _, target := range strings.Split(targets, ",") {
+			name, dest, found := strings.Cut(strings.TrimSpace(target), "=")
+			if !found || name == "" || dest == "" {
+				slog.Warn("Invalid SSH target", "value", target)
+				continue
+			}
+			if _, exists := rm.addrs[name]; exists {
+				slog.Warn("SSH target has the same name as a remote", "name", name)
+				continue
+			}
+			rm.targets[name] = newSshTarget(dest, signer)
+			slog.Info("SSH target", "name", name, "dest", dest)
+		}
+	}
+	return rm
+}
+
+// Returns the stats of every remote. Failures are reported per remote.
+func (rm *remoteManager) getAll() []system.RemoteData {
+	names := make([]string, 0, len(rm.addrs)+len(rm.targets))
+	for name := range rm.addrs {
+		names = append(names, name)
+	}
+	for name := range rm.targets {
+		names = append(names, name)
+	}
+	results := make([]system.RemoteData, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = rm.get(name)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Returns the stats of the named remote
+func (rm *remoteManager) get(name string) system.RemoteData {
+	result := system.RemoteData{Name: name}
+	var data *system.CombinedData
+	var err error
+	if target, ok := rm.targets[name]; ok {
+		data, err = target.collect()
+	} else if _, ok := rm.addrs[name]; ok {
+		data, err = rm.request(name)
+	} else {
+		result.Error = "unknown remote"
+		return result
+	}
+	if err != nil {
+		slog.Debug("Error relaying remote", "name", name, "err", err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Data = data
+	return result
+}
+
+// Fetches stats from the remote agent, reconnecting once if the
+// existing connection was closed
+func (rm *remoteManager) request(name string) (*system.CombinedData, error) {
+	client, err := rm.getClient(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := requestRemoteStats(client)
+	if err != nil {
+		rm.closeClient(name)
+		if client, err = rm.getClient(name); err != nil {
+			return nil, err
+		}
+		data, err = requestRemoteStats(client)
+	}
+	return data, err
+}
+
+// Returns an existing connection to the remote or dials a new one
+func (rm *remoteManager) getClient(name string) (*ssh.Client, error) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if client, ok := rm.clients[name]; ok {
+		return client, nil
+	}
+	client, err := ssh.Dial("tcp", rm.addrs[name], rm.config)
+	if err != nil {
+		return nil, err
+	}
+	rm.clients[name] = client
+	return client, nil
+}
+
+func (rm *remoteManager) closeClient(name string) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if client, ok := rm.clients[name]; ok {
+		client.Close()
+		delete(rm.clients, name)
+	}
+}
+
+// Runs a session on the remote agent and decodes its stats
+func requestRemoteStats(client *ssh.Client) (*system.CombinedData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	type result struct {
+		data *system.CombinedData
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		session, err := client.NewSession()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer session.Close()
+		stdout, err := session.StdoutPipe()
+		if err == nil {
+			err = session.Shell()
+		}
+		var data system.CombinedData
+		if err == nil {
+			err = json.NewDecoder(stdout).Decode(&data)
+		}
+		if err == nil {
+			err = session.Wait()
+		}
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{data: &data}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("remote timed out")
+	}
+}
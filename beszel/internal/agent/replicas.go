@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Label shortcuts accepted by CONTAINER_GROUP_BY
+var replicaGroupLabels = map[string]string{
+	"compose": "com.docker.compose.service",
+	"swarm":   "com.docker.swarm.service.name",
+}
+
+// Rolls up replicas of the same service into a single container entry
+type replicaGrouper struct {
+	label      string         // group by the value of this label
+	pattern    *regexp.Regexp // or by the first submatch (or whole match) of the name
+	keepDetail bool           // also report the individual replicas
+}
+
+// Parses CONTAINER_GROUP_BY, which is "compose", "swarm", "label:<key>", or
+// "name:<regex>". Returns nil if unset or invalid.
+func newReplicaGrouper() *replicaGrouper {
+	groupBy, exists := os.LookupEnv("CONTAINER_GROUP_BY")
+	if !exists || groupBy == "" {
+		return nil
+	}
+	rg := &replicaGrouper{}
+	if label, ok := replicaGroupLabels[groupBy]; ok {
+		rg.label = label
+	} else if label, found := strings.CutPrefix(groupBy, "label:"); found && label != "" {
+		rg.label = label
+	} else if expr, found := strings.CutPrefix(groupBy, "name:"); found {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			slog.Warn("Invalid CONTAINER_GROUP_BY pattern", "err", err)
+			return nil
+		}
+		rg.pattern = pattern
+	} else {
+		slog.Warn("Invalid CONTAINER_GROUP_BY", "value", groupBy)
+		return nil
+	}
+	rg.keepDetail, _ = strconv.ParseBool(os.Getenv("CONTAINER_GROUP_DETAIL"))
+	slog.Info("CONTAINER_GROUP_BY", "value", groupBy, "detail", rg.keepDetail)
+	return rg
+}
+
+// Returns the group of the container, or an empty string if it isn't grouped
+func (rg *replicaGrouper) groupName(ctr container.ApiInfo) string {
+	if rg.label != "" {
+		return ctr.Labels[rg.label]
+	}
+	match := rg.pattern.FindStringSubmatch(strings.TrimPrefix(ctr.Names[0], "/"))
+	switch {
+	case len(match) > 1:
+		return match[1]
+	case len(match) == 1:
+		return match[0]
+	}
+	return ""
+}
+
+// Orders container health from best to worst for rolled-up entries
+var healthRank = map[string]int{"": 0, "healthy": 1, "starting": 2, "unhealthy": 3}
+
+// Replaces grouped containers with one entry per group. Usage and network are
+// summed, and the spread of cpu and memory across replicas is reported.
+// groups maps container ids to group names. Health is that of the least
+// healthy replica.
+func (rg *replicaGrouper) merge(stats map[string]*container.Stats, groups map[string]string) []*container.Stats {
+	result := make([]*container.Stats, 0, len(stats))
+	merged := make(map[string]*container.Stats)
+	for id, ctr := range stats {
+		group := groups[id]
+		if group == "" || rg.keepDetail {
+			result = append(result, ctr)
+		}
+		if group == "" {
+			continue
+		}
+		rollup, ok := merged[group]
+		if !ok {
+			rollup = &container.Stats{
+				Name:   group,
+				Spread: &container.ReplicaSpread{CpuMin: ctr.Cpu, MemMin: ctr.Mem},
+			}
+			merged[group] = rollup
+			result = append(result, rollup)
+		}
+		rollup.Replicas++
+		rollup.Cpu += ctr.Cpu
+		rollup.Mem += ctr.Mem
+		rollup.SwapUsed += ctr.SwapUsed
+		rollup.NetworkSent += ctr.NetworkSent
+		rollup.NetworkRecv += ctr.NetworkRecv
+		rollup.DiskReadPs += ctr.DiskReadPs
+		rollup.DiskWritePs += ctr.DiskWritePs
+		rollup.LogErrors += ctr.LogErrors
+		rollup.Restarts += ctr.Restarts
+		rollup.OomKills += ctr.OomKills
+		rollup.Image = ctr.Image
+		// the most recently started replica
+		if rollup.Uptime == 0 || (ctr.Uptime > 0 && ctr.Uptime < rollup.Uptime) {
+			rollup.Uptime = ctr.Uptime
+		}
+		// the least healthy replica
+		if healthRank[ctr.Health] > healthRank[rollup.Health] {
+			rollup.Health = ctr.Health
+		}
+		// the replica closest to its memory limit
+		rollup.MemPct = max(rollup.MemPct, ctr.MemPct)
+		rollup.UpdateAvailable = rollup.UpdateAvailable || ctr.UpdateAvailable
+		spread := rollup.Spread
+		spread.CpuMin = min(spread.CpuMin, ctr.Cpu)
+		spread.CpuMax = max(spread.CpuMax, ctr.Cpu)
+		spread.MemMin = min(spread.MemMin, ctr.Mem)
+		spread.MemMax = max(spread.MemMax, ctr.Mem)
+	}
+	for _, rollup := range merged {
+		replicas := float64(rollup.Replicas)
+		rollup.Cpu = smallDecimals(rollup.Cpu)
+		rollup.Mem = twoDecimals(rollup.Mem)
+		rollup.SwapUsed = twoDecimals(rollup.SwapUsed)
+		rollup.NetworkSent = smallDecimals(rollup.NetworkSent)
+		rollup.NetworkRecv = smallDecimals(rollup.NetworkRecv)
+		rollup.DiskReadPs = smallDecimals(rollup.DiskReadPs)
+		rollup.DiskWritePs = smallDecimals(rollup.DiskWritePs)
+		rollup.Spread.CpuAvg = smallDecimals(rollup.Cpu / replicas)
+		rollup.Spread.MemAvg = twoDecimals(rollup.Mem / replicas)
+	}
+	return result
+}
@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"runtime"
+	"time"
+)
+
+// How often to read runtime.MemStats, which briefly stops the world
+const memStatsInterval = time.Minute
+
+// Updates the agent's own goroutine count and memory usage in system info.
+// A steadily climbing goroutine count indicates a leak in the collectors.
+// A new struct is set each time because earlier payloads share the pointer.
+func (a *Agent) updateAgentRuntime() {
+	var rt system.AgentRuntime
+	if a.systemInfo.AgentRuntime != nil {
+		rt = *a.systemInfo.AgentRuntime
+	}
+	rt.Goroutines = runtime.NumGoroutine()
+	if time.Since(a.memStatsTime) >= memStatsInterval {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		a.memStatsTime = time.Now()
+		rt.HeapAlloc = bytesToMegabytes(float64(m.HeapAlloc))
+		rt.NumGC = m.NumGC
+	}
+	a.systemInfo.AgentRuntime = &rt
+}
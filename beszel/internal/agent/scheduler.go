@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Shortest accepted collection interval
+const minCollectInterval = time.Second
+
+// Collects stats every INTERVAL when it's set, so rates are computed over a
+// fixed period instead of the time between requests. Requests are served the
+// latest collection, or collect on demand if the schedule falls two intervals
+// behind. Data meant for the hub (events, out of memory kills) is kept until
+// the hub requests it, so ticks between requests don't lose it. Stops when ctx
+// is cancelled.
+func (a *Agent) startScheduler(ctx context.Context) {
+	val, exists := os.LookupEnv("INTERVAL")
+	if !exists || val == "" {
+		return
+	}
+	interval, err := parseCollectInterval(val)
+	if err != nil {
+		slog.Warn("Invalid INTERVAL", "err", err)
+		return
+	}
+	slog.Info("INTERVAL", "interval", interval)
+	a.collectMutex.Lock()
+	// the cache holds the scheduled collections, so it can't stay disabled
+	if a.statsCache.ttl == 0 {
+		slog.Warn("STATS_CACHE_TTL=0 is ignored when INTERVAL is set")
+	}
+	a.statsCache.ttl = 2 * interval
+	a.collectMutex.Unlock()
+	go runScheduler(ctx, interval, func() {
+		a.collectMutex.Lock()
+		defer a.collectMutex.Unlock()
+		a.collectFreshStats()
+	})
+}
+
+// Parses an INTERVAL value, which must be a duration of at least minCollectInterval
+func parseCollectInterval(val string) (time.Duration, error) {
+	interval, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, err
+	}
+	if interval < minCollectInterval {
+		return 0, fmt.Errorf("interval %s is below the minimum of %s", interval, minCollectInterval)
+	}
+	return interval, nil
+}
+
+// Calls collect immediately and then every interval until ctx is cancelled
+func runScheduler(ctx context.Context, interval time.Duration, collect func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		collect()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseCollectInterval(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1s", time.Second, false},
+		{"30s", 30 * time.Second, false},
+		{"2m", 2 * time.Minute, false},
+		{"999ms", 0, true},
+		{"0", 0, true},
+		{"-5s", 0, true},
+		{"30", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCollectInterval(tt.val)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseCollectInterval(%q) = %v, %v; want %v, error %v", tt.val, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRunScheduler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		runScheduler(ctx, 10*time.Millisecond, func() { calls.Add(1) })
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := calls.Load(); got < 3 {
+		t.Fatalf("collect called %d times; want at least 3", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler didn't stop after cancel")
+	}
+	stopped := calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != stopped {
+		t.Errorf("collect called %d times after stopping", got-stopped)
+	}
+}
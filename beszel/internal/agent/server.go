@@ -1,31 +1,121 @@
 package agent
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
-	"os"
+	"strings"
+	"time"
 
 	sshServer "github.com/gliderlabs/ssh"
 )
 
-func (a *Agent) startServer(pubKey []byte, addr string) {
-	sshServer.Handle(a.handleSession)
+// Maximum time to wait for HTTP requests to finish when shutting down
+const shutdownTimeout = 10 * time.Second
+
+// Serves SSH until ctx is cancelled, then shuts down the SSH and HTTP servers
+func (a *Agent) startServer(ctx context.Context, pubKey []byte, addr string) error {
+	authorizedKeys, err := parseAuthorizedKeys(pubKey)
+	if err != nil {
+		return err
+	}
+	server := &sshServer.Server{Addr: addr, Handler: a.handleSession}
+	server.SetOption(sshServer.NoPty())
+	server.SetOption(sshServer.PublicKeyAuth(func(ctx sshServer.Context, key sshServer.PublicKey) bool {
+		for _, allowed := range authorizedKeys {
+			if sshServer.KeysEqual(key, allowed) {
+				return true
+			}
+		}
+		return false
+	}))
 
 	slog.Info("Starting SSH server", "address", addr)
-	if err := sshServer.ListenAndServe(addr, nil, sshServer.NoPty(),
-		sshServer.PublicKeyAuth(func(ctx sshServer.Context, key sshServer.PublicKey) bool {
-			allowed, _, _, _, _ := sshServer.ParseAuthorizedKey(pubKey)
-			return sshServer.KeysEqual(key, allowed)
-		}),
-	); err != nil {
-		slog.Error("Error starting SSH server", "err", err)
-		os.Exit(1)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("ssh server: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down")
+	// wait for in-flight sessions, then close connections. The hub keeps its
+	// connection open between requests, so waiting for it to close would hang.
+	a.sessionMutex.Lock()
+	err = server.Close()
+	a.sessionMutex.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, httpServer := range a.httpServers {
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Error shutting down HTTP server", "addr", httpServer.Addr, "err", err)
+		}
+	}
+	if a.dockerManager != nil {
+		a.dockerManager.client.CloseIdleConnections()
 	}
+	return err
+}
+
+// Parses the public keys allowed to connect, separated by newlines (as in an
+// authorized_keys file) or commas, so several hubs can connect or a key can be
+// rotated without downtime. Keys that can't be parsed are skipped.
+func parseAuthorizedKeys(data []byte) ([]sshServer.PublicKey, error) {
+	var keys []sshServer.PublicKey
+	for _, line := range strings.FieldsFunc(string(data), func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := sshServer.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			slog.Warn("Invalid public key", "err", err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no valid public key in KEY")
+	}
+	if len(keys) > 1 {
+		slog.Info("Authorized keys", "count", len(keys))
+	}
+	return keys, nil
 }
 
 func (a *Agent) handleSession(s sshServer.Session) {
+	a.sessionMutex.RLock()
+	defer a.sessionMutex.RUnlock()
+	a.polls.record()
+	// relay stats from remote agents
+	if a.remoteManager != nil {
+		if name, found := strings.CutPrefix(s.RawCommand(), "remote "); found {
+			writeSession(s, a.remoteManager.get(strings.TrimSpace(name)))
+			return
+		}
+		if s.RawCommand() == "remotes" {
+			writeSession(s, a.remoteManager.getAll())
+			return
+		}
+	}
 	stats := a.gatherStats()
-	if err := json.NewEncoder(s).Encode(stats); err != nil {
+	var payload any = stats
+	// "flat" command returns a flattened key-value map instead of the structured data
+	if s.RawCommand() == "flat" {
+		payload = flattenStats(&stats, a.baseUnits)
+	}
+	writeSession(s, payload)
+}
+
+// Encodes payload as json to the session and exits
+func writeSession(s sshServer.Session, payload any) {
+	if err := json.NewEncoder(s).Encode(payload); err != nil {
 		slog.Error("Error encoding stats", "err", err)
 		s.Exit(1)
 		return
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// How often to query drives. SMART data changes slowly and queries can wake sleeping disks.
+	smartInterval = 10 * time.Minute
+	smartTimeout  = 30 * time.Second
+)
+
+// ATA attribute ids reported individually
+const (
+	smartReallocatedSectors = 5
+	smartPendingSectors     = 197
+)
+
+// Subset of smartctl --json output
+type smartctlOutput struct {
+	Devices []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"devices"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			Id  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+type smartManager struct {
+	health  map[string]system.DiskHealth
+	updated time.Time // Time of the last successful read
+	mutex   sync.Mutex
+}
+
+// Returns a new smartManager and starts collecting in the background, or nil
+// if smartctl is missing, lacks privileges, or finds no drives
+func newSmartManager(ctx context.Context) *smartManager {
+	health, err := readSmartHealth()
+	if err == nil && len(health) == 0 {
+		err = errors.New("no devices found")
+	}
+	if err != nil {
+		slog.Debug("SMART", "err", err)
+		return nil
+	}
+	sm := &smartManager{health: health, updated: time.Now()}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(smartInterval):
+			}
+			health, err := readSmartHealth()
+			if err != nil {
+				slog.Warn("Error reading SMART data", "err", err)
+				continue
+			}
+			sm.mutex.Lock()
+			sm.health = health
+			sm.updated = time.Now()
+			sm.mutex.Unlock()
+		}
+	}()
+	return sm
+}
+
+// Returns a copy of the latest health of each drive
+func (sm *smartManager) getHealth() map[string]system.DiskHealth {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return maps.Clone(sm.health)
+}
+
+// Returns the time of the last successful read
+func (sm *smartManager) lastUpdate() time.Time {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.updated
+}
+
+// Queries the overall health and key attributes of every drive smartctl finds
+func readSmartHealth() (map[string]system.DiskHealth, error) {
+	var scan smartctlOutput
+	if err := runSmartctl(&scan, "--scan"); err != nil {
+		return nil, err
+	}
+	health := make(map[string]system.DiskHealth, len(scan.Devices))
+	for _, device := range scan.Devices {
+		var out smartctlOutput
+		if err := runSmartctl(&out, "-H", "-A", "-d", device.Type, device.Name); err != nil {
+			slog.Debug("SMART", "device", device.Name, "err", err)
+			continue
+		}
+		// drives without SMART support don't report a status
+		if out.SmartStatus == nil {
+			continue
+		}
+		h := system.DiskHealth{
+			Passed:      out.SmartStatus.Passed,
+			Temperature: out.Temperature.Current,
+		}
+		for _, attr := range out.AtaSmartAttributes.Table {
+			switch attr.Id {
+			case smartReallocatedSectors:
+				h.Reallocated = attr.Raw.Value
+			case smartPendingSectors:
+				h.Pending = attr.Raw.Value
+			}
+		}
+		health[filepath.Base(device.Name)] = h
+	}
+	return health, nil
+}
+
+// Runs smartctl with json output and decodes it into out. smartctl's exit
+// status is a bitmask, and only the low two bits (bad arguments, device
+// couldn't be opened) mean the output is unusable.
+func runSmartctl(out *smartctlOutput, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), smartTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "smartctl", append([]string{"--json"}, args...)...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode()&0b11 == 0 {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(output, out)
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"beszel/internal/entities/container"
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TCP states from include/net/tcp_states.h, as written in /proc/net/tcp
+const (
+	tcpEstablished = "01"
+	tcpTimeWait    = "06"
+	tcpListen      = "0A"
+)
+
+// Tallies TCP socket states in the network namespace of the process.
+// Requires access to the host PID namespace when running in a container.
+func readSocketCounts(pid int) (*container.SocketCounts, error) {
+	counts := &container.SocketCounts{}
+	for _, name := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/net/" + name)
+		if err != nil {
+			// tcp6 is missing if ipv6 is disabled
+			if name == "tcp6" && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		countSocketStates(data, counts)
+	}
+	return counts, nil
+}
+
+// Adds the socket states in /proc/net/tcp output to counts
+func countSocketStates(data []byte, counts *container.SocketCounts) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// skip header
+	scanner.Scan()
+	for scanner.Scan() {
+		// Example line: 0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000 ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		switch fields[3] {
+		case tcpEstablished:
+			counts.Established++
+		case tcpListen:
+			counts.Listen++
+		case tcpTimeWait:
+			counts.TimeWait++
+		}
+	}
+}
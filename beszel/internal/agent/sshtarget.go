@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Shell script run on agentless hosts. Each section starts with a line
+// beginning with "@" so the output can be split without relying on the
+// exact format of each command.
+const sshTargetScript = `echo @stat; head -n 1 /proc/stat
+echo @meminfo; cat /proc/meminfo
+echo @uptime; cat /proc/uptime
+echo @netdev; cat /proc/net/dev
+echo @df; df -kP /
+echo @host; hostname; uname -r; grep -c ^processor /proc/cpuinfo; grep -m 1 "model name" /proc/cpuinfo`
+
+// A host without the agent whose stats are collected over SSH.
+// Only standard Linux /proc files and coreutils are required.
+type sshTarget struct {
+	addr     string
+	config   *ssh.ClientConfig
+	client   *ssh.Client
+	prevCpu  [2]uint64 // total and idle jiffies at the last collection
+	prevNet  [2]uint64 // bytes sent and received at the last collection
+	prevTime time.Time
+	mutex    sync.Mutex
+}
+
+// Returns a new sshTarget for a destination of the form user@host[:port]
+func newSshTarget(dest string, signer ssh.Signer) *sshTarget {
+	user, addr, found := strings.Cut(dest, "@")
+	if !found {
+		user, addr = "root", dest
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	return &sshTarget{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         4 * time.Second,
+		},
+	}
+}
+
+// Runs the collection script on the host and converts the output to stats.
+// The connection is reused between collections and dropped on any error.
+func (t *sshTarget) collect() (*system.CombinedData, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.client == nil {
+		client, err := ssh.Dial("tcp", t.addr, t.config)
+		if err != nil {
+			return nil, err
+		}
+		t.client = client
+	}
+	output, err := runSshCommand(t.client, sshTargetScript)
+	if err != nil {
+		t.client.Close()
+		t.client = nil
+		return nil, err
+	}
+	return t.parse(splitSshSections(output))
+}
+
+// Runs command in a new session on the client, giving up after remoteTimeout
+func runSshCommand(client *ssh.Client, command string) ([]byte, error) {
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		session, err := client.NewSession()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer session.Close()
+		output, err := session.Output(command)
+		done <- result{output, err}
+	}()
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-time.After(remoteTimeout):
+		return nil, fmt.Errorf("command timed out")
+	}
+}
+
+// Splits script output into sections keyed by their "@name" header
+func splitSshSections(output []byte) map[string][]string {
+	sections := make(map[string][]string)
+	var current string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, found := strings.CutPrefix(line, "@"); found {
+			current = name
+			continue
+		}
+		if current != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+	return sections
+}
+
+// Converts the script output into stats, using the previous collection for rates
+func (t *sshTarget) parse(sections map[string][]string) (*system.CombinedData, error) {
+	data := &system.CombinedData{}
+	stats := &data.Stats
+	info := &data.Info
+
+	// cpu: "cpu user nice system idle iowait irq softirq steal ..."
+	if lines := sections["stat"]; len(lines) > 0 {
+		fields := strings.Fields(lines[0])
+		if len(fields) < 5 || fields[0] != "cpu" {
+			return nil, errors.New("unexpected /proc/stat format")
+		}
+		var total, idle uint64
+		for i, field := range fields[1:min(len(fields), 9)] {
+			value, _ := strconv.ParseUint(field, 10, 64)
+			total += value
+			// idle and iowait
+			if i == 3 || i == 4 {
+				idle += value
+			}
+		}
+		totalDelta, totalOk := counterDelta(t.prevCpu[0], total)
+		idleDelta, idleOk := counterDelta(t.prevCpu[1], idle)
+		if !t.prevTime.IsZero() && totalOk && idleOk && totalDelta > 0 {
+			stats.Cpu = twoDecimals(100 * (1 - float64(idleDelta)/float64(totalDelta)))
+		}
+		t.prevCpu = [2]uint64{total, idle}
+	}
+
+	// memory
+	meminfo := parseMeminfo([]byte(strings.Join(sections["meminfo"], "\n")))
+	if memTotal := meminfo["MemTotal"]; memTotal > 0 {
+		memUsed := memTotal - min(memTotal, meminfo["MemAvailable"])
+		stats.Mem = bytesToGigabytes(memTotal * 1024)
+		stats.MemUsed = bytesToGigabytes(memUsed * 1024)
+		stats.MemBuffCache = bytesToGigabytes((meminfo["Buffers"] + meminfo["Cached"] + meminfo["SReclaimable"]) * 1024)
+		stats.MemPct = twoDecimals(float64(memUsed) / float64(memTotal) * 100)
+		stats.Swap = bytesToGigabytes(meminfo["SwapTotal"] * 1024)
+		stats.SwapUsed = bytesToGigabytes((meminfo["SwapTotal"] - min(meminfo["SwapTotal"], meminfo["SwapFree"])) * 1024)
+	}
+
+	// root disk usage: "Filesystem 1024-blocks Used Available Capacity Mounted on"
+	if lines := sections["df"]; len(lines) > 1 {
+		if fields := strings.Fields(lines[len(lines)-1]); len(fields) >= 4 {
+			diskTotal, _ := strconv.ParseUint(fields[1], 10, 64)
+			diskUsed, _ := strconv.ParseUint(fields[2], 10, 64)
+			stats.DiskTotal = bytesToGigabytes(diskTotal * 1024)
+			stats.DiskUsed = bytesToGigabytes(diskUsed * 1024)
+			if diskTotal > 0 {
+				stats.DiskPct = twoDecimals(float64(diskUsed) / float64(diskTotal) * 100)
+			}
+		}
+	}
+
+	// network
+	bytesSent, bytesRecv := parseNetDev([]byte(strings.Join(sections["netdev"], "\n")))
+	sentDelta, sentOk := counterDelta(t.prevNet[0], bytesSent)
+	recvDelta, recvOk := counterDelta(t.prevNet[1], bytesRecv)
+	if !t.prevTime.IsZero() && sentOk && recvOk {
+		secondsElapsed := time.Since(t.prevTime).Seconds()
+		stats.NetworkSent = bytesToMegabytes(float64(sentDelta) / secondsElapsed)
+		stats.NetworkRecv = bytesToMegabytes(float64(recvDelta) / secondsElapsed)
+	}
+	t.prevNet = [2]uint64{bytesSent, bytesRecv}
+	t.prevTime = time.Now()
+
+	// host info: hostname, kernel, processor count, model name
+	host := sections["host"]
+	if len(host) > 0 {
+		info.Hostname = host[0]
+	}
+	if len(host) > 1 {
+		info.KernelVersion = host[1]
+	}
+	if len(host) > 2 {
+		info.Threads, _ = strconv.Atoi(host[2])
+		info.Cores = info.Threads
+	}
+	if len(host) > 3 {
+		if _, model, found := strings.Cut(host[3], ":"); found {
+			info.CpuModel = strings.TrimSpace(model)
+		}
+	}
+	if lines := sections["uptime"]; len(lines) > 0 {
+		if fields := strings.Fields(lines[0]); len(fields) > 0 {
+			uptime, _ := strconv.ParseFloat(fields[0], 64)
+			info.Uptime = uint64(uptime)
+		}
+	}
+	info.Cpu = stats.Cpu
+	info.MemPct = stats.MemPct
+	info.DiskPct = stats.DiskPct
+	info.Bandwidth = twoDecimals(stats.NetworkSent + stats.NetworkRecv)
+
+	return data, nil
+}
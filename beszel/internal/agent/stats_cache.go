@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Default time collected stats are reused for
+const defaultStatsCacheTtl = 900 * time.Millisecond
+
+// Reuses the last collected stats for rapid requests, e.g. two hubs polling at
+// once. Stats are kept encoded so each caller decodes its own copy and can't
+// share maps with the cache or the collectors' previous-cycle state. Rates
+// aren't affected because collectors compute them over the time since their
+// own last read.
+type statsCache struct {
+	ttl   time.Duration
+	data  []byte
+	taken time.Time
+}
+
+// Returns a cache with the STATS_CACHE_TTL duration (default 900ms, 0 disables)
+func newStatsCache() *statsCache {
+	sc := &statsCache{ttl: defaultStatsCacheTtl}
+	if val, exists := os.LookupEnv("STATS_CACHE_TTL"); exists {
+		if ttl, err := time.ParseDuration(val); err == nil && ttl >= 0 {
+			sc.ttl = ttl
+		} else {
+			slog.Warn("Invalid STATS_CACHE_TTL", "value", val)
+		}
+	}
+	return sc
+}
+
+// Returns a copy of the cached stats if they are fresh
+func (sc *statsCache) get() (system.CombinedData, bool) {
+	var data system.CombinedData
+	if sc.ttl == 0 || sc.data == nil || time.Since(sc.taken) > sc.ttl {
+		return data, false
+	}
+	err := json.Unmarshal(sc.data, &data)
+	return data, err == nil
+}
+
+// Caches the stats and returns a copy that doesn't share memory with them.
+// The copy is made even when caching is disabled, since the collectors keep
+// using the maps in data after the lock is released.
+func (sc *statsCache) set(data system.CombinedData) system.CombinedData {
+	var copied system.CombinedData
+	encoded, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(encoded, &copied)
+	}
+	if err != nil {
+		slog.Error("Error copying stats", "err", err)
+		return copied
+	}
+	if sc.ttl > 0 {
+		sc.data, sc.taken = encoded, time.Now()
+	}
+	return copied
+}
@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"testing"
+	"time"
+)
+
+func TestStatsCacheSetCopies(t *testing.T) {
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		sc := &statsCache{ttl: ttl}
+		data := system.CombinedData{}
+		data.Stats.Temperatures = map[string]float64{"cpu": 40}
+		copied := sc.set(data)
+		data.Stats.Temperatures["cpu"] = 90
+		if got := copied.Stats.Temperatures["cpu"]; got != 40 {
+			t.Errorf("ttl %s: copy changed with the original: %v", ttl, got)
+		}
+		if _, ok := sc.get(); ok != (ttl > 0) {
+			t.Errorf("ttl %s: get ok = %v", ttl, ok)
+		}
+	}
+}
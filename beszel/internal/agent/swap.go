@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pages swapped in and out from the previous collection
+type swapCounters struct {
+	in   uint64
+	out  uint64
+	time time.Time
+}
+
+// Sets the swap in and out rates in MB/s from /proc/vmstat. Leaves them at
+// zero on the first call, if a counter reset, or on other platforms.
+func (a *Agent) setSwapRates(systemStats *system.Stats) {
+	data, err := os.ReadFile("/proc/vmstat")
+	if err != nil {
+		return
+	}
+	var current swapCounters
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Example line: pswpin 1024
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "pswpin":
+			current.in, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "pswpout":
+			current.out, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	current.time = time.Now()
+	prev := a.prevSwap
+	a.prevSwap = current
+	if prev.time.IsZero() {
+		return
+	}
+	inDelta, inOk := counterDelta(prev.in, current.in)
+	outDelta, outOk := counterDelta(prev.out, current.out)
+	secondsElapsed := current.time.Sub(prev.time).Seconds()
+	if !inOk || !outOk || secondsElapsed <= 0 {
+		return
+	}
+	pageSize := float64(os.Getpagesize())
+	systemStats.SwapInPs = smallDecimals(float64(inDelta) * pageSize / secondsElapsed / 1048576)
+	systemStats.SwapOutPs = smallDecimals(float64(outDelta) * pageSize / secondsElapsed / 1048576)
+}
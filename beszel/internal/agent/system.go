@@ -4,30 +4,71 @@ import (
 	"beszel"
 	"beszel/internal/entities/system"
 	"bufio"
+	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 	psutilNet "github.com/shirou/gopsutil/v4/net"
 	"github.com/shirou/gopsutil/v4/sensors"
 )
 
+// Maximum number of cores reported by TRACK_PER_CORE
+const maxPerCoreCount = 1024
+
+// Rates above these mean a counter was reset rather than wrapped (#150)
+const (
+	maxNetBytesPerSecond  = 10_000 * 1048576
+	maxDiskBytesPerSecond = 50_000 * 1048576
+	maxDiskOpsPerSecond   = 10_000_000
+)
+
 // Sets initial / non-changing values about the host system
 func (a *Agent) initializeSystemInfo() {
 	a.systemInfo.AgentVersion = beszel.Version
 	a.systemInfo.Hostname, _ = os.Hostname()
 	a.systemInfo.KernelVersion, _ = host.KernelVersion()
+	// in a container this is the image's distribution unless HOST_ETC is set
+	if platform, _, version, err := host.PlatformInformation(); err == nil {
+		a.systemInfo.Os = platform
+		a.systemInfo.OsVersion = version
+	}
+	if a.systemInfo.Os == "" {
+		a.systemInfo.Os = runtime.GOOS
+	}
+	if arch, err := host.KernelArch(); err == nil && arch != "" {
+		a.systemInfo.Arch = arch
+	} else {
+		a.systemInfo.Arch = runtime.GOARCH
+	}
+	a.systemInfo.KernelCmdline = getKernelCmdline()
+	a.systemInfo.TimeZone = getTimeZone()
+	a.systemInfo.Locale = getLocale()
+	// read once so later wall clock changes don't move it and look like a reboot
+	if bootTime, err := host.BootTime(); err == nil {
+		a.systemInfo.BootTime = int64(bootTime)
+	}
 
 	// cpu model
 	if info, err := cpu.Info(); err == nil && len(info) > 0 {
 		a.systemInfo.CpuModel = info[0].ModelName
+		// cpu.Info reports the max frequency on Linux, so it's only a fallback
+		a.systemInfo.CpuFreq = readCpuFreq()
+		if a.systemInfo.CpuFreq == 0 {
+			a.systemInfo.CpuFreq = averageCpuFreq(info)
+		}
 	}
 	// cores / threads
 	a.systemInfo.Cores, _ = cpu.Counts(false)
@@ -46,141 +87,339 @@ func (a *Agent) initializeSystemInfo() {
 	} else {
 		slog.Debug("Not monitoring ZFS ARC", "err", err)
 	}
+
+	// pressure stall information (Linux 4.20+)
+	if _, err := os.Stat("/proc/pressure"); err == nil {
+		a.psi = true
+	} else {
+		slog.Debug("Not monitoring pressure", "err", err)
+	}
 }
 
-// Returns current info, stats about the host system
+// Returns current info, stats about the host system. Independent collectors
+// run concurrently so one slow subsystem (e.g. a hung mount or sensor) doesn't
+// delay the others. Each collector owns the previous-cycle state it updates
+// (fsStats, netIoStats, cpu counters) and writes separate fields of the stats.
 func (a *Agent) getSystemStats() system.Stats {
 	systemStats := system.Stats{}
 
+	// forget rate history of counters that are gone
+	a.rateWindow.prune()
+
+	collectors := []func(*system.Stats){
+		a.setCpuStats,
+		a.setMemStats,
+		a.setDiskStats,
+		a.setNetStats,
+		a.setSensorStats,
+		a.setPressureStats,
+	}
+	var wg sync.WaitGroup
+	for _, collect := range collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collect(&systemStats)
+		}()
+	}
+	wg.Wait()
+
+	// update base system info
+	a.systemInfo.Cpu = systemStats.Cpu
+	// keep the previous reading if the frequency isn't available this time
+	if freq := readCpuFreq(); freq > 0 {
+		a.systemInfo.CpuFreq = freq
+	}
+	a.systemInfo.MemPct = systemStats.MemPct
+	a.systemInfo.DiskPct = systemStats.DiskPct
+	if uptime, err := host.Uptime(); err == nil {
+		a.checkReboot(uptime)
+		a.systemInfo.Uptime = uptime
+	}
+	a.systemInfo.Bandwidth = twoDecimals(systemStats.NetworkSent + systemStats.NetworkRecv)
+	if a.collectorEnabled("net") && time.Since(a.netInfoTime) > netInfoInterval {
+		a.updateNetInterfaceInfo()
+	}
+	if a.userStatsManager != nil {
+		a.systemInfo.Users = a.userStatsManager.getUsers()
+	}
+	if a.processManager != nil {
+		a.systemInfo.Processes = a.processManager.getProcesses()
+	}
+	if a.dnsProbe != nil {
+		a.systemInfo.Dns = a.dnsProbe.getStatus()
+	}
+	if a.smartManager != nil {
+		a.systemInfo.DiskHealth = a.smartManager.getHealth()
+	}
+	if a.systemdManager != nil {
+		a.systemInfo.SystemState = a.systemdManager.getState()
+	}
+	if a.publicIpManager != nil {
+		a.systemInfo.PublicIP = a.publicIpManager.getIp()
+	}
+	if a.collectorEnabled("powercap") {
+		// read each update since firmware can lower the cap at runtime
+		a.systemInfo.PowerCap = getPowerCap()
+	}
+	if a.collectorEnabled("battery") {
+		a.systemInfo.Battery = getBattery()
+	}
+	if a.collectorEnabled("runtime") {
+		a.updateAgentRuntime()
+	}
+	a.updateFreshness()
+	slog.Debug("sysinfo", "data", a.systemInfo)
+
+	return systemStats
+}
+
+// Sets cpu usage, load, and process counts
+func (a *Agent) setCpuStats(systemStats *system.Stats) {
 	// cpu percent
-	cpuPct, err := cpu.Percent(0, false)
-	if err != nil {
-		slog.Error("Error getting cpu percent", "err", err)
-	} else if len(cpuPct) > 0 {
+	if a.collectorEnabled("cpu") {
+		cpuPct, err := cpu.Percent(0, false)
+		if pct, ok := a.windowedCpuPercent(); ok && err == nil {
+			cpuPct = []float64{pct}
+		}
+		a.setCpuPercent(systemStats, cpuPct, err)
+		if a.perCore {
+			systemStats.CpuPerCore = getCpuPerCore()
+		}
+		a.setCpuWaitStats(systemStats)
+		a.setSwitchRates(systemStats)
+		setProcessCounts(systemStats)
+		// load average (not available on windows, so fail quietly)
+		if avg, err := load.Avg(); err == nil {
+			systemStats.LoadAvg1 = twoDecimals(avg.Load1)
+			systemStats.LoadAvg5 = twoDecimals(avg.Load5)
+			systemStats.LoadAvg15 = twoDecimals(avg.Load15)
+			if a.systemInfo.Cores > 0 {
+				systemStats.LoadPerCore = twoDecimals(avg.Load1 / float64(a.systemInfo.Cores))
+			}
+		} else {
+			slog.Debug("Error getting load average", "err", err)
+		}
+	}
+}
+
+// Sets cpu usage from the result of cpu.Percent. If it failed or returned no
+// values, keeps the last good value rather than reporting a false 0%.
+func (a *Agent) setCpuPercent(systemStats *system.Stats, cpuPct []float64, err error) {
+	if err == nil && len(cpuPct) > 0 {
 		systemStats.Cpu = twoDecimals(cpuPct[0])
+		a.lastCpu = systemStats.Cpu
+		return
 	}
+	slog.Error("Error getting cpu percent", "err", err, "values", len(cpuPct))
+	systemStats.Cpu = a.lastCpu
+	systemStats.CpuFailed = true
+}
 
+// Sets memory, swap, and memory bandwidth
+func (a *Agent) setMemStats(systemStats *system.Stats) {
 	// memory
-	if v, err := mem.VirtualMemory(); err == nil {
-		// swap
-		systemStats.Swap = bytesToGigabytes(v.SwapTotal)
-		systemStats.SwapUsed = bytesToGigabytes(v.SwapTotal - v.SwapFree - v.SwapCached)
-		// cache + buffers value for default mem calculation
-		cacheBuff := v.Total - v.Free - v.Used
-		// htop memory calculation overrides
-		if a.memCalc == "htop" {
-			// note: gopsutil automatically adds SReclaimable to v.Cached
-			cacheBuff = v.Cached + v.Buffers - v.Shared
-			v.Used = v.Total - (v.Free + cacheBuff)
-			v.UsedPercent = float64(v.Used) / float64(v.Total) * 100.0
-		}
-		// subtract ZFS ARC size from used memory and add as its own category
-		if a.zfs {
-			if arcSize, _ := getARCSize(); arcSize > 0 && arcSize < v.Used {
-				v.Used = v.Used - arcSize
+	if a.collectorEnabled("mem") {
+		if v, err := mem.VirtualMemory(); err == nil {
+			// swap
+			systemStats.Swap = bytesToGigabytes(v.SwapTotal)
+			systemStats.SwapUsed = bytesToGigabytes(v.SwapTotal - v.SwapFree - v.SwapCached)
+			// cache + buffers value for default mem calculation
+			cacheBuff := v.Total - v.Free - v.Used
+			// htop memory calculation overrides
+			if a.memCalc == "htop" {
+				// note: gopsutil automatically adds SReclaimable to v.Cached
+				cacheBuff = v.Cached + v.Buffers - v.Shared
+				v.Used = v.Total - (v.Free + cacheBuff)
 				v.UsedPercent = float64(v.Used) / float64(v.Total) * 100.0
-				systemStats.MemZfsArc = bytesToGigabytes(arcSize)
 			}
+			// subtract ZFS ARC size from used memory and add as its own category
+			if a.zfs {
+				if arcSize, _ := getARCSize(); arcSize > 0 && arcSize < v.Used {
+					v.Used = v.Used - arcSize
+					v.UsedPercent = float64(v.Used) / float64(v.Total) * 100.0
+					systemStats.MemZfsArc = bytesToGigabytes(arcSize)
+				}
+			}
+			systemStats.Mem = bytesToGigabytes(v.Total)
+			systemStats.MemBuffCache = bytesToGigabytes(cacheBuff)
+			systemStats.MemUsed = bytesToGigabytes(v.Used)
+			systemStats.MemPct = twoDecimals(v.UsedPercent)
+		}
+		setMemBreakdown(systemStats)
+		a.setSwapRates(systemStats)
+		if _, thrashing, ok := readPressure("memory"); ok {
+			systemStats.MemThrashing = twoDecimals(thrashing)
 		}
-		systemStats.Mem = bytesToGigabytes(v.Total)
-		systemStats.MemBuffCache = bytesToGigabytes(cacheBuff)
-		systemStats.MemUsed = bytesToGigabytes(v.Used)
-		systemStats.MemPct = twoDecimals(v.UsedPercent)
+	}
+
+	// memory bandwidth
+	if a.memBandwidth != nil {
+		systemStats.MemBandwidth = a.getMemBandwidth()
+	}
+}
+
+// Sets usage and i/o of monitored filesystems, updating fsStats
+func (a *Agent) setDiskStats(systemStats *system.Stats) {
+	// pick up filesystems that were mounted or removed since the last refresh
+	if a.deviceRefresh > 0 && a.collectorEnabled("disk") && time.Since(a.fsRefreshTime) > a.deviceRefresh {
+		a.refreshDiskInfo()
 	}
 
 	// disk usage
-	for _, stats := range a.fsStats {
-		if d, err := disk.Usage(stats.Mountpoint); err == nil {
-			stats.DiskTotal = bytesToGigabytes(d.Total)
-			stats.DiskUsed = bytesToGigabytes(d.Used)
-			if stats.Root {
-				systemStats.DiskTotal = bytesToGigabytes(d.Total)
-				systemStats.DiskUsed = bytesToGigabytes(d.Used)
-				systemStats.DiskPct = twoDecimals(d.UsedPercent)
+	a.updateDiskUsage(systemStats)
+
+	// disk i/o
+	if len(a.fsNames) > 0 {
+		if ioCounters, err := disk.IOCounters(a.fsNames...); err == nil {
+			for _, d := range ioCounters {
+				stats := a.fsStats[d.Name]
+				if stats == nil {
+					continue
+				}
+				secondsElapsed := time.Since(stats.Time).Seconds()
+				maxBytes := uint64(maxDiskBytesPerSecond * secondsElapsed)
+				maxOps := uint64(maxDiskOpsPerSecond * secondsElapsed)
+				readDelta, readOk := wrappingDelta(stats.TotalRead, d.ReadBytes, maxBytes)
+				writeDelta, writeOk := wrappingDelta(stats.TotalWrite, d.WriteBytes, maxBytes)
+				readOpsDelta, readOpsOk := wrappingDelta(stats.TotalReadOps, d.ReadCount, maxOps)
+				writeOpsDelta, writeOpsOk := wrappingDelta(stats.TotalWriteOps, d.WriteCount, maxOps)
+				// millisecond counters can't plausibly grow by more than 2^32 (49 days) per update
+				ioTimeDelta, _ := wrappingDelta(stats.TotalIoTime, d.IoTime, math.MaxUint32)
+				opTimeDelta, _ := wrappingDelta(stats.TotalOpTime, d.ReadTime+d.WriteTime, math.MaxUint32)
+				// counters were reset (e.g. device replaced), so re-baseline and report zero this cycle
+				if !readOk || !writeOk || !readOpsOk || !writeOpsOk {
+					slog.Debug("Disk I/O counter reset", "name", d.Name)
+					readDelta, writeDelta, readOpsDelta, writeOpsDelta = 0, 0, 0, 0
+					ioTimeDelta, opTimeDelta = 0, 0
+				}
+				if deltas, seconds, ok := a.rateWindow.deltas("disk."+d.Name, d.ReadBytes, d.WriteBytes, d.IoTime, d.ReadCount, d.WriteCount, d.ReadTime+d.WriteTime); ok {
+					readDelta, writeDelta, ioTimeDelta, secondsElapsed = deltas[0], deltas[1], deltas[2], seconds
+					readOpsDelta, writeOpsDelta, opTimeDelta = deltas[3], deltas[4], deltas[5]
+				}
+				stats.Time = time.Now()
+				stats.DiskReadPs = a.megabytesPerSecond(float64(readDelta) / secondsElapsed)
+				stats.DiskWritePs = a.megabytesPerSecond(float64(writeDelta) / secondsElapsed)
+				stats.DiskUtil = diskUtilization(ioTimeDelta, secondsElapsed)
+				stats.DiskReadOpsPs = twoDecimals(float64(readOpsDelta) / secondsElapsed)
+				stats.DiskWriteOpsPs = twoDecimals(float64(writeOpsDelta) / secondsElapsed)
+				stats.DiskAwait = diskAwait(opTimeDelta, readOpsDelta+writeOpsDelta)
+				stats.TotalRead = d.ReadBytes
+				stats.TotalWrite = d.WriteBytes
+				stats.TotalIoTime = d.IoTime
+				stats.TotalReadOps = d.ReadCount
+				stats.TotalWriteOps = d.WriteCount
+				stats.TotalOpTime = d.ReadTime + d.WriteTime
+				// if root filesystem, update system stats
+				if stats.Root {
+					systemStats.DiskReadPs = stats.DiskReadPs
+					systemStats.DiskWritePs = stats.DiskWritePs
+					systemStats.DiskUtil = stats.DiskUtil
+					systemStats.DiskReadOpsPs = stats.DiskReadOpsPs
+					systemStats.DiskWriteOpsPs = stats.DiskWriteOpsPs
+					systemStats.DiskAwait = stats.DiskAwait
+				}
 			}
-		} else {
-			// reset stats if error (likely unmounted)
-			slog.Error("Error getting disk stats", "name", stats.Mountpoint, "err", err)
-			stats.DiskTotal = 0
-			stats.DiskUsed = 0
-			stats.TotalRead = 0
-			stats.TotalWrite = 0
 		}
 	}
 
-	// disk i/o
-	if ioCounters, err := disk.IOCounters(a.fsNames...); err == nil {
-		for _, d := range ioCounters {
-			stats := a.fsStats[d.Name]
-			if stats == nil {
-				continue
-			}
-			secondsElapsed := time.Since(stats.Time).Seconds()
-			readPerSecond := bytesToMegabytes(float64(d.ReadBytes-stats.TotalRead) / secondsElapsed)
-			writePerSecond := bytesToMegabytes(float64(d.WriteBytes-stats.TotalWrite) / secondsElapsed)
-			// check for invalid values and reset stats if so
-			if readPerSecond < 0 || writePerSecond < 0 || readPerSecond > 50_000 || writePerSecond > 50_000 {
-				slog.Warn("Invalid disk I/O. Resetting.", "name", d.Name, "read", readPerSecond, "write", writePerSecond)
-				a.initializeDiskIoStats(ioCounters)
-				break
+	// filesystem error counts, root disk type, and tail latency
+	for device, stats := range a.fsStats {
+		if a.latencyManager != nil {
+			stats.LatencyP99 = a.latencyManager.getP99(device)
+			if stats.Root {
+				systemStats.DiskLatencyP99 = stats.LatencyP99
 			}
-			stats.Time = time.Now()
-			stats.DiskReadPs = readPerSecond
-			stats.DiskWritePs = writePerSecond
-			stats.TotalRead = d.ReadBytes
-			stats.TotalWrite = d.WriteBytes
-			// if root filesystem, update system stats
+		}
+		if count, ok := readFsErrors(device); ok {
+			stats.FsErrors = count
 			if stats.Root {
-				systemStats.DiskReadPs = stats.DiskReadPs
-				systemStats.DiskWritePs = stats.DiskWritePs
+				systemStats.DiskErrors = count
 			}
 		}
+		if stats.Root {
+			systemStats.DiskType = stats.DiskType
+			systemStats.DiskTransport = stats.Transport
+			systemStats.DiskFstype = stats.Fstype
+		}
 	}
+}
 
+// Sets bandwidth and connection stats, updating netIoStats
+func (a *Agent) setNetStats(systemStats *system.Stats) {
 	// network stats
-	if netIO, err := psutilNet.IOCounters(true); err == nil {
-		secondsElapsed := time.Since(a.netIoStats.Time).Seconds()
-		a.netIoStats.Time = time.Now()
-		bytesSent := uint64(0)
-		bytesRecv := uint64(0)
-		// sum all bytes sent and received
-		for _, v := range netIO {
-			// skip if not in valid network interfaces list
-			if _, exists := a.netInterfaces[v.Name]; !exists {
-				continue
+	if a.collectorEnabled("net") {
+		if netIO, err := psutilNet.IOCounters(true); err == nil {
+			secondsElapsed := time.Since(a.netIoStats.Time).Seconds()
+			a.netIoStats.Time = time.Now()
+			// pick up interfaces that came up or went away since the last refresh
+			var interfacesChanged bool
+			if a.deviceRefresh > 0 && time.Since(a.netRefreshTime) > a.deviceRefresh {
+				if interfacesChanged = a.refreshNetInterfaces(netIO); interfacesChanged {
+					// read link settings of new interfaces after this collection
+					a.netInfoTime = time.Time{}
+				}
 			}
-			bytesSent += v.BytesSent
-			bytesRecv += v.BytesRecv
-		}
-		// add to systemStats
-		sentPerSecond := float64(bytesSent-a.netIoStats.BytesSent) / secondsElapsed
-		recvPerSecond := float64(bytesRecv-a.netIoStats.BytesRecv) / secondsElapsed
-		networkSentPs := bytesToMegabytes(sentPerSecond)
-		networkRecvPs := bytesToMegabytes(recvPerSecond)
-		// add check for issue (#150) where sent is a massive number
-		if networkSentPs > 10_000 || networkRecvPs > 10_000 {
-			slog.Warn("Invalid net stats. Resetting.", "sent", networkSentPs, "recv", networkRecvPs)
+			errors, drops := uint64(0), uint64(0)
+			// sum errors and drops of valid interfaces
 			for _, v := range netIO {
 				if _, exists := a.netInterfaces[v.Name]; !exists {
 					continue
 				}
-				slog.Info(v.Name, "recv", v.BytesRecv, "sent", v.BytesSent)
+				errors += v.Errin + v.Errout
+				drops += v.Dropin + v.Dropout
+			}
+			// errors and drops (left at zero if either counter went backwards, or the
+			// sums cover different interfaces than last time)
+			errorsDelta, errorsOk := counterDelta(a.netIoStats.Errors, errors)
+			dropsDelta, dropsOk := counterDelta(a.netIoStats.Drops, drops)
+			if errorsOk && dropsOk && !interfacesChanged && secondsElapsed > 0 {
+				systemStats.NetworkErrorsPs = twoDecimals(float64(errorsDelta) / secondsElapsed)
+				systemStats.NetworkDropsPs = twoDecimals(float64(dropsDelta) / secondsElapsed)
+			}
+			a.netIoStats.Errors = errors
+			a.netIoStats.Drops = drops
+			interfaceStats, sentDelta, recvDelta := a.getInterfaceStats(netIO)
+			if len(interfaceStats) > 0 {
+				systemStats.Interfaces = interfaceStats
+			}
+			// totals are summed from the interface deltas, so they never go backwards
+			// when a single interface's counter wraps or resets
+			a.netIoStats.BytesSent += sentDelta
+			a.netIoStats.BytesRecv += recvDelta
+			if deltas, seconds, ok := a.rateWindow.deltas("net", a.netIoStats.BytesSent, a.netIoStats.BytesRecv); ok {
+				sentDelta, recvDelta, secondsElapsed = deltas[0], deltas[1], seconds
+			}
+			if secondsElapsed > 0 {
+				systemStats.NetworkSent = a.megabytesPerSecond(float64(sentDelta) / secondsElapsed)
+				systemStats.NetworkRecv = a.megabytesPerSecond(float64(recvDelta) / secondsElapsed)
 			}
-			// reset network I/O stats
-			a.initializeNetIoStats()
-		} else {
-			systemStats.NetworkSent = networkSentPs
-			systemStats.NetworkRecv = networkRecvPs
-			// update netIoStats
-			a.netIoStats.BytesSent = bytesSent
-			a.netIoStats.BytesRecv = bytesRecv
 		}
 	}
 
-	// temperatures (skip if sensors whitelist is set to empty string)
-	if a.sensorsWhitelist != nil && len(a.sensorsWhitelist) == 0 {
+	// connections by state
+	if a.connTracker != nil {
+		systemStats.Connections = a.connTracker.getCounts()
+	}
+
+	// connection tracking table usage
+	if a.optionalCollectorEnabled("conntrack", "CONNTRACK") {
+		setConntrackStats(systemStats)
+	}
+
+	// network namespace stats
+	if len(a.netNsStats) > 0 {
+		systemStats.NetNs = a.getNetNsStats()
+	}
+}
+
+// Sets temperatures, fans, IPMI sensors, custom metrics, and GPU data
+func (a *Agent) setSensorStats(systemStats *system.Stats) {
+	// temperatures and fans (skip if sensors collector is disabled or whitelist is set to empty string)
+	if !a.collectorEnabled("sensors") || (a.sensorsWhitelist != nil && len(a.sensorsWhitelist) == 0) {
 		slog.Debug("Skipping temperature collection")
 	} else {
+		systemStats.Fans = getFanSpeeds(a.sensorsContext)
 		temps, err := sensors.TemperaturesWithContext(a.sensorsContext)
 		if err != nil {
 			slog.Debug("Sensor error", "err", err)
@@ -188,30 +427,52 @@ func (a *Agent) getSystemStats() system.Stats {
 		slog.Debug("Temperature", "sensors", temps)
 		if len(temps) > 0 {
 			systemStats.Temperatures = make(map[string]float64, len(temps))
-			for i, sensor := range temps {
-				// skip if temperature is 0
-				if sensor.Temperature <= 0 || sensor.Temperature >= 200 {
-					continue
+			// duplicate keys get _1, _2, ... in the order the sensors are read, counted
+			// before validation so a sensor keeps its key when another one is dropped
+			seen := make(map[string]int, len(temps))
+			for _, sensor := range temps {
+				key := sensor.SensorKey
+				if n := seen[sensor.SensorKey]; n > 0 {
+					key += "_" + strconv.Itoa(n)
 				}
-				if _, ok := systemStats.Temperatures[sensor.SensorKey]; ok {
-					// if key already exists, append int to key
-					systemStats.Temperatures[sensor.SensorKey+"_"+strconv.Itoa(i)] = twoDecimals(sensor.Temperature)
-				} else {
-					systemStats.Temperatures[sensor.SensorKey] = twoDecimals(sensor.Temperature)
+				seen[sensor.SensorKey]++
+				if a.sensorsWhitelist != nil {
+					if _, ok := a.sensorsWhitelist[key]; !ok {
+						continue
+					}
+				}
+				if label, ok := a.sensorLabels[key]; ok {
+					key = label
 				}
+				if !a.validTemperature(key, sensor.Temperature) {
+					continue
+				}
+				systemStats.Temperatures[key] = twoDecimals(sensor.Temperature)
 			}
-			// remove sensors from systemStats if whitelist exists and sensor is not in whitelist
-			// (do this here instead of in initial loop so we have correct keys if int was appended)
-			if a.sensorsWhitelist != nil {
-				for key := range systemStats.Temperatures {
-					if _, nameInWhitelist := a.sensorsWhitelist[key]; !nameInWhitelist {
-						delete(systemStats.Temperatures, key)
-					}
+		}
+	}
+
+	// IPMI sensors
+	if a.ipmiManager != nil {
+		if sensors := a.ipmiManager.getSensors(); len(sensors) > 0 {
+			systemStats.Ipmi = sensors
+			// add temperatures
+			if systemStats.Temperatures == nil {
+				systemStats.Temperatures = make(map[string]float64)
+			}
+			for name, sensor := range sensors {
+				if sensor.Unit == "degrees C" && a.validTemperature(name, sensor.Value) {
+					systemStats.Temperatures[name] = sensor.Value
 				}
 			}
 		}
 	}
 
+	// custom metrics
+	if len(a.customMetrics) > 0 {
+		systemStats.Custom = a.getCustomMetrics()
+	}
+
 	// GPU data
 	if a.gpuManager != nil {
 		if gpuData := a.gpuManager.GetCurrentData(); len(gpuData) > 0 {
@@ -221,22 +482,143 @@ func (a *Agent) getSystemStats() system.Stats {
 				systemStats.Temperatures = make(map[string]float64, len(gpuData))
 			}
 			for _, gpu := range gpuData {
-				if gpu.Temperature > 0 {
+				if a.validTemperature(gpu.Name, gpu.Temperature) {
 					systemStats.Temperatures[gpu.Name] = gpu.Temperature
 				}
 			}
 		}
 	}
+}
 
-	// update base system info
-	a.systemInfo.Cpu = systemStats.Cpu
-	a.systemInfo.MemPct = systemStats.MemPct
-	a.systemInfo.DiskPct = systemStats.DiskPct
-	a.systemInfo.Uptime, _ = host.Uptime()
-	a.systemInfo.Bandwidth = twoDecimals(systemStats.NetworkSent + systemStats.NetworkRecv)
-	slog.Debug("sysinfo", "data", a.systemInfo)
+// Sets iowait and steal as a percent of cpu time since the previous call.
+// Both stay at zero on the first call and if the counters went backwards.
+func (a *Agent) setCpuWaitStats(systemStats *system.Stats) {
+	times, err := cpu.Times(false)
+	if err != nil || len(times) == 0 {
+		return
+	}
+	current := times[0]
+	prev := a.prevCpuTimes
+	a.prevCpuTimes = &current
+	if prev == nil {
+		return
+	}
+	totalDelta := cpuTimesTotal(current) - cpuTimesTotal(*prev)
+	iowaitDelta := current.Iowait - prev.Iowait
+	stealDelta := current.Steal - prev.Steal
+	if totalDelta <= 0 || iowaitDelta < 0 || stealDelta < 0 {
+		return
+	}
+	systemStats.CpuIowait = twoDecimals(iowaitDelta / totalDelta * 100)
+	systemStats.CpuSteal = twoDecimals(stealDelta / totalDelta * 100)
+}
 
-	return systemStats
+// Returns the total cpu time. Guest time is already counted in user time.
+func cpuTimesTotal(t cpu.TimesStat) float64 {
+	return t.User + t.System + t.Idle + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal
+}
+
+// Returns the average current clock speed (MHz) of all cpus from cpufreq in
+// sysfs, or 0 if it isn't available (e.g. not Linux or no cpufreq driver)
+func readCpuFreq() float64 {
+	paths, _ := filepath.Glob(filepath.Join(sysfsRoot(context.Background()), "devices", "system", "cpu", "cpu[0-9]*", "cpufreq", "scaling_cur_freq"))
+	var sum, count float64
+	for _, path := range paths {
+		// value is in kHz
+		if khz, err := strconv.ParseFloat(readSysfsString(path), 64); err == nil && khz > 0 {
+			sum += khz / 1000
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return math.Round(sum / count)
+}
+
+// Returns the average clock speed (MHz) of the cpu entries that report one
+func averageCpuFreq(info []cpu.InfoStat) float64 {
+	var sum, count float64
+	for _, c := range info {
+		if c.Mhz > 0 {
+			sum += c.Mhz
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return math.Round(sum / count)
+}
+
+// Returns the usage percent of each logical core in /proc/stat order, which
+// is stable between calls. Systems with more than maxPerCoreCount cores are truncated.
+func getCpuPerCore() []float64 {
+	pcts, err := cpu.Percent(0, true)
+	if err != nil {
+		slog.Debug("Error getting per core cpu percent", "err", err)
+		return nil
+	}
+	if len(pcts) > maxPerCoreCount {
+		pcts = pcts[:maxPerCoreCount]
+	}
+	for i := range pcts {
+		pcts[i] = twoDecimals(pcts[i])
+	}
+	return pcts
+}
+
+// Returns false for readings that are exactly zero (usually a disconnected probe)
+// or outside the TEMP_MIN / TEMP_MAX range
+func (a *Agent) validTemperature(name string, temp float64) bool {
+	if temp == 0 || temp < a.tempRange[0] || temp > a.tempRange[1] {
+		slog.Debug("Dropping temperature", "sensor", name, "value", temp)
+		return false
+	}
+	return true
+}
+
+// Returns the IANA name of the host time zone, or an empty string if it can't be determined
+func getTimeZone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if data, err := os.ReadFile("/etc/timezone"); err == nil {
+		if tz := strings.TrimSpace(string(data)); tz != "" {
+			return tz
+		}
+	}
+	// /etc/localtime is usually a symlink into the zoneinfo database
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, tz, found := strings.Cut(target, "zoneinfo/"); found {
+			return tz
+		}
+	}
+	if tz := time.Local.String(); tz != "Local" {
+		return tz
+	}
+	return ""
+}
+
+// Returns the host locale from the environment or /etc/locale.conf
+func getLocale() string {
+	for _, key := range []string{"LC_ALL", "LANG"} {
+		if locale := os.Getenv(key); locale != "" {
+			return locale
+		}
+	}
+	for _, path := range []string{"/etc/locale.conf", "/etc/default/locale"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if locale, found := strings.CutPrefix(strings.TrimSpace(line), "LANG="); found {
+				return strings.Trim(locale, `"'`)
+			}
+		}
+	}
+	return ""
 }
 
 // Returns the size of the ZFS ARC memory cache in bytes
@@ -250,13 +632,9 @@ func getARCSize() (uint64, error) {
 	// Scan the lines
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "size") {
-			// Example line: size 4 15032385536
-			fields := strings.Fields(line)
-			if len(fields) < 3 {
-				return 0, err
-			}
+		// Example line: size 4 15032385536
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "size" {
 			// Return the size as uint64
 			return strconv.ParseUint(fields[2], 10, 64)
 		}
@@ -264,3 +642,20 @@ func getARCSize() (uint64, error) {
 
 	return 0, fmt.Errorf("failed to parse size field")
 }
+
+// Seconds uptime may go backwards from rounding before it counts as a reboot
+const rebootTolerance = 5
+
+// Updates the boot time if uptime went backwards, which means the host
+// rebooted while the agent kept running (e.g. a restored VM snapshot). Uptime
+// is measured from the kernel's boot clock, so it isn't moved by wall clock
+// changes the way a recomputed boot time would be.
+func (a *Agent) checkReboot(uptime uint64) {
+	if a.systemInfo.Uptime == 0 || uptime+rebootTolerance >= a.systemInfo.Uptime {
+		return
+	}
+	slog.Info("Host rebooted", "uptime", uptime, "prev", a.systemInfo.Uptime)
+	if bootTime, err := host.BootTime(); err == nil {
+		a.systemInfo.BootTime = int64(bootTime)
+	}
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"errors"
+	"testing"
+)
+
+func TestSetCpuPercent(t *testing.T) {
+	tests := []struct {
+		name       string
+		cpuPct     []float64
+		err        error
+		want       float64
+		wantFailed bool
+	}{
+		{"value", []float64{42.123}, nil, 42.12, false},
+		{"empty slice", []float64{}, nil, 12.5, true},
+		{"nil slice", nil, nil, 12.5, true},
+		{"error", []float64{50}, errors.New("read failed"), 12.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{lastCpu: 12.5}
+			var stats system.Stats
+			a.setCpuPercent(&stats, tt.cpuPct, tt.err)
+			if stats.Cpu != tt.want || stats.CpuFailed != tt.wantFailed {
+				t.Errorf("Cpu = %v, CpuFailed = %v; want %v, %v", stats.Cpu, stats.CpuFailed, tt.want, tt.wantFailed)
+			}
+			if !tt.wantFailed && a.lastCpu != tt.want {
+				t.Errorf("lastCpu = %v; want %v", a.lastCpu, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	systemStateInterval = time.Minute
+	systemStateTimeout  = 5 * time.Second
+)
+
+// Periodically checks the overall systemd state (running, degraded, maintenance, ...)
+type systemStateManager struct {
+	state   string
+	updated time.Time
+	mutex   sync.Mutex
+}
+
+// Returns a new systemStateManager and starts checking in the background,
+// or nil if systemctl is not installed
+func newSystemStateManager(ctx context.Context) *systemStateManager {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		slog.Debug("systemd state", "err", err)
+		return nil
+	}
+	sm := &systemStateManager{}
+	sm.update()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(systemStateInterval):
+			}
+			sm.update()
+		}
+	}()
+	return sm
+}
+
+// Runs systemctl is-system-running. It exits non-zero for any state other
+// than running, so the output is used as long as there is one.
+func (sm *systemStateManager) update() {
+	ctx, cancel := context.WithTimeout(context.Background(), systemStateTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "systemctl", "is-system-running").Output()
+	state := strings.TrimSpace(string(output))
+	// "offline" means systemd isn't the init system (e.g. in a container)
+	if state == "" || state == "offline" {
+		slog.Debug("systemd state", "state", state, "err", err)
+		state = ""
+	}
+	sm.mutex.Lock()
+	sm.state = state
+	sm.updated = time.Now()
+	sm.mutex.Unlock()
+}
+
+// Returns the last known state, or an empty string if systemd isn't running
+func (sm *systemStateManager) getState() string {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.state
+}
+
+// Returns the time of the last check
+func (sm *systemStateManager) lastUpdate() time.Time {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	return sm.updated
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const sysCpuDir = "/sys/devices/system/cpu"
+
+// Reads socket / core / thread topology and cache sizes from sysfs.
+// Returns nil where the topology isn't exposed (non-Linux, some VMs).
+func getCpuTopology() *system.CpuTopology {
+	cpuDirs, _ := filepath.Glob(filepath.Join(sysCpuDir, "cpu[0-9]*"))
+	sockets := make(map[string]struct{})
+	cores := make(map[string]struct{})
+	threads := 0
+	for _, dir := range cpuDirs {
+		pkg, err := os.ReadFile(filepath.Join(dir, "topology/physical_package_id"))
+		if err != nil {
+			continue
+		}
+		core, err := os.ReadFile(filepath.Join(dir, "topology/core_id"))
+		if err != nil {
+			continue
+		}
+		pkgId := strings.TrimSpace(string(pkg))
+		sockets[pkgId] = struct{}{}
+		cores[pkgId+":"+strings.TrimSpace(string(core))] = struct{}{}
+		threads++
+	}
+	if threads == 0 {
+		return nil
+	}
+
+	topology := &system.CpuTopology{
+		Sockets:        len(sockets),
+		CoresPerSocket: len(cores) / len(sockets),
+		ThreadsPerCore: threads / len(cores),
+	}
+
+	// cache sizes of the first cpu (per instance, e.g. L2 per core, L3 per socket or CCX)
+	cacheDirs, _ := filepath.Glob(filepath.Join(sysCpuDir, "cpu0/cache/index[0-9]*"))
+	for _, dir := range cacheDirs {
+		level := readSysfsString(filepath.Join(dir, "level"))
+		cacheType := readSysfsString(filepath.Join(dir, "type"))
+		size := parseCacheSize(readSysfsString(filepath.Join(dir, "size")))
+		switch {
+		case level == "1" && cacheType == "Data":
+			topology.L1d = size
+		case level == "1" && cacheType == "Instruction":
+			topology.L1i = size
+		case level == "2":
+			topology.L2 = size
+		case level == "3":
+			topology.L3 = size
+		}
+	}
+	return topology
+}
+
+func readSysfsString(path string) string {
+	data, _ := os.ReadFile(path)
+	return strings.TrimSpace(string(data))
+}
+
+// Parses a sysfs cache size such as "32K" or "8M" into KB
+func parseCacheSize(size string) int {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(size, "K"):
+		size = strings.TrimSuffix(size, "K")
+	case strings.HasSuffix(size, "M"):
+		size = strings.TrimSuffix(size, "M")
+		multiplier = 1024
+	}
+	n, _ := strconv.Atoi(size)
+	return n * multiplier
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"beszel/internal/entities/system"
+	"cmp"
+	"context"
+	"log/slog"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// How often to aggregate process usage by user (enumerating processes is expensive)
+const userStatsInterval = 30 * time.Second
+
+type userStatsManager struct {
+	topN      int                // Number of users to report by cpu and by memory
+	cpuCount  int                // Logical cpus, used to scale cpu percent to the whole host
+	prevCpu   map[int32]float64  // Cpu seconds for each pid at the last sample
+	prevTime  time.Time          // Time of the last sample
+	usernames map[uint32]string  // Cache of uid to username lookups
+	users     []system.UserStats // Latest top users
+	updated   time.Time          // Time of the last successful collection
+	mutex     sync.Mutex
+}
+
+// Returns a new userStatsManager and starts collecting in the background
+func newUserStatsManager(ctx context.Context, cpuCount int) *userStatsManager {
+	topN := 5
+	if n, err := strconv.Atoi(os.Getenv("TOP_USERS")); err == nil && n > 0 {
+		topN = n
+	}
+	um := &userStatsManager{
+		topN:      topN,
+		cpuCount:  max(cpuCount, 1),
+		usernames: make(map[uint32]string),
+	}
+	go um.startCollector(ctx)
+	return um
+}
+
+// Refreshes user stats on an interval
+func (um *userStatsManager) startCollector(ctx context.Context) {
+	for {
+		users, err := um.collect()
+		if err != nil {
+			slog.Warn("Error getting user stats", "err", err)
+		} else {
+			um.mutex.Lock()
+			um.users = users
+			um.updated = time.Now()
+			um.mutex.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(userStatsInterval):
+		}
+	}
+}
+
+// Returns the latest top users
+func (um *userStatsManager) getUsers() []system.UserStats {
+	um.mutex.Lock()
+	defer um.mutex.Unlock()
+	return slices.Clone(um.users)
+}
+
+// Returns the time of the last successful collection
+func (um *userStatsManager) lastUpdate() time.Time {
+	um.mutex.Lock()
+	defer um.mutex.Unlock()
+	return um.updated
+}
+
+// Aggregates cpu and memory of all processes by owning user and returns the top users.
+// Cpu is the share of total host cpu since the previous sample, so the first run reports zero cpu.
+func (um *userStatsManager) collect() ([]system.UserStats, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	secondsElapsed := time.Since(um.prevTime).Seconds()
+	firstRun := um.prevTime.IsZero()
+	um.prevTime = time.Now()
+
+	cpuTimes := make(map[int32]float64, len(procs))
+	byUid := make(map[uint32]*system.UserStats)
+	for _, p := range procs {
+		uids, err := p.Uids()
+		if err != nil || len(uids) == 0 {
+			continue
+		}
+		stats, ok := byUid[uids[0]]
+		if !ok {
+			stats = &system.UserStats{Name: um.lookupUsername(uids[0])}
+			byUid[uids[0]] = stats
+		}
+		stats.Procs++
+		if mem, err := p.MemoryInfo(); err == nil {
+			stats.Mem += float64(mem.RSS)
+		}
+		if times, err := p.Times(); err == nil {
+			total := times.User + times.System
+			cpuTimes[p.Pid] = total
+			// only count cpu used since the last sample by processes that existed then
+			if prev, ok := um.prevCpu[p.Pid]; ok && !firstRun && total >= prev {
+				stats.Cpu += total - prev
+			}
+		}
+	}
+	um.prevCpu = cpuTimes
+
+	users := make([]system.UserStats, 0, len(byUid))
+	for _, stats := range byUid {
+		if !firstRun && secondsElapsed > 0 {
+			stats.Cpu = twoDecimals(stats.Cpu / secondsElapsed / float64(um.cpuCount) * 100)
+		} else {
+			stats.Cpu = 0
+		}
+		stats.Mem = bytesToMegabytes(stats.Mem)
+		users = append(users, *stats)
+	}
+	return topUsers(users, um.topN), nil
+}
+
+// Returns users in the top n by cpu or by memory, sorted by cpu
+func topUsers(users []system.UserStats, n int) []system.UserStats {
+	if len(users) <= n {
+		slices.SortFunc(users, func(a, b system.UserStats) int { return cmp.Compare(b.Cpu, a.Cpu) })
+		return users
+	}
+	top := make(map[string]system.UserStats, n*2)
+	slices.SortFunc(users, func(a, b system.UserStats) int { return cmp.Compare(b.Mem, a.Mem) })
+	for _, u := range users[:n] {
+		top[u.Name] = u
+	}
+	slices.SortFunc(users, func(a, b system.UserStats) int { return cmp.Compare(b.Cpu, a.Cpu) })
+	for _, u := range users[:n] {
+		top[u.Name] = u
+	}
+	result := make([]system.UserStats, 0, len(top))
+	for _, u := range users {
+		if _, ok := top[u.Name]; ok {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// Returns the username for a uid, or the uid itself if it can't be resolved
+func (um *userStatsManager) lookupUsername(uid uint32) string {
+	if name, ok := um.usernames[uid]; ok {
+		return name
+	}
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	um.usernames[uid] = name
+	return name
+}
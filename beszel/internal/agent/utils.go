@@ -10,6 +10,67 @@ func bytesToGigabytes(b uint64) float64 {
 	return twoDecimals(float64(b) / 1073741824)
 }
 
+// Upper bound for PRECISION, beyond which the float64 values are noise
+const maxRatePrecision = 6
+
+// Converts bytes per second to MB/s, rounded to PRECISION decimals (two by default)
+func (a *Agent) megabytesPerSecond(bytesPerSecond float64) float64 {
+	scale := math.Pow10(a.ratePrecision)
+	return math.Round(bytesPerSecond/1048576*scale) / scale
+}
+
+// Converts bytes per second to MB/s, keeping small rates with smallDecimals
+func smallMegabytesPerSecond(bytesPerSecond float64) float64 {
+	return smallDecimals(bytesPerSecond / 1048576)
+}
+
 func twoDecimals(value float64) float64 {
 	return math.Round(value*100) / 100
 }
+
+// Rounds to two decimals, keeping up to four for values below 0.01 so that
+// low but nonzero rates (e.g. 3 KB/s = 0.0029 MB/s) aren't reported as zero.
+func smallDecimals(value float64) float64 {
+	if math.Abs(value) >= 0.01 {
+		return twoDecimals(value)
+	}
+	return math.Round(value*10000) / 10000
+}
+
+// Returns the increase of a cumulative counter since the previous reading.
+// If the counter went backwards (e.g. daemon restart), returns 0 and false
+// so the caller can re-baseline instead of reporting a huge rate.
+func counterDelta(prev, current uint64) (uint64, bool) {
+	if current < prev {
+		return 0, false
+	}
+	return current - prev, true
+}
+
+// Distance below 2^32 within which a drop of a 32 bit counter is a wrap. It's
+// fixed because maxDelta usually covers the whole 32 bit range, which would
+// turn any reset of a counter below 4 GiB (e.g. a recreated VPN interface)
+// into gigabytes of traffic.
+const wrapWindow32 = 1 << 30
+
+// Returns the increase of a counter that may wrap around. A reading below the
+// previous one is only a wrap if the previous reading was near 2^32 (within
+// wrapWindow32 and maxDelta, e.g. 32 bit kernels) or within maxDelta of 2^64.
+// Any other drop, or a result above maxDelta, means the counter was reset, so
+// returns 0 and false.
+func wrappingDelta(prev, current, maxDelta uint64) (uint64, bool) {
+	delta := current - prev // unsigned subtraction wraps at 2^64
+	if current < prev {
+		switch {
+		case prev <= math.MaxUint32 && prev > math.MaxUint32-min(maxDelta, wrapWindow32):
+			delta = math.MaxUint32 - prev + current + 1
+		case prev > math.MaxUint64-maxDelta:
+		default:
+			return 0, false
+		}
+	}
+	if delta > maxDelta {
+		return 0, false
+	}
+	return delta, true
+}
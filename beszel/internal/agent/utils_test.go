@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWrappingDelta(t *testing.T) {
+	tests := []struct {
+		name          string
+		prev, current uint64
+		maxDelta      uint64
+		want          uint64
+		wantOk        bool
+	}{
+		{"increase", 1000, 1500, 1000, 500, true},
+		{"unchanged", 1000, 1000, 1000, 0, true},
+		{"32 bit wrap", math.MaxUint32 - 99, 200, 1000, 300, true},
+		{"32 bit wrap to zero", math.MaxUint32, 0, 1000, 1, true},
+		{"64 bit wrap", math.MaxUint64 - 99, 200, 1000, 300, true},
+		{"reset of 32 bit value", 1 << 30, 0, 1000, 0, false},
+		{"reset of 64 bit value", 1 << 40, 10, 1000, 0, false},
+		{"32 bit wrap with large max delta", math.MaxUint32 - 99, 200, math.MaxUint32 + 1, 300, true},
+		{"reset of 500MB interface with large max delta", 500 << 20, 0, 60 * maxNetBytesPerSecond, 0, false},
+		{"reset just outside the 32 bit window", math.MaxUint32 - wrapWindow32, 0, 1 << 40, 0, false},
+		{"32 bit wrap at the edge of the window", math.MaxUint32 - wrapWindow32 + 1, 0, 1 << 40, wrapWindow32, true},
+		{"increase above max delta", 1000, 5000, 1000, 0, false},
+		{"32 bit wrap above max delta", math.MaxUint32 - 99, 2000, 1000, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := wrappingDelta(tt.prev, tt.current, tt.maxDelta)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("wrappingDelta(%d, %d, %d) = %d, %v; want %d, %v", tt.prev, tt.current, tt.maxDelta, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name          string
+		prev, current uint64
+		want          uint64
+		wantOk        bool
+	}{
+		{"increase", 100, 250, 150, true},
+		{"unchanged", 100, 100, 0, true},
+		{"from zero", 0, 42, 42, true},
+		{"reset", 1000, 10, 0, false},
+		{"reset to zero", math.MaxUint64, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := counterDelta(tt.prev, tt.current)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("counterDelta(%d, %d) = %d, %v; want %d, %v", tt.prev, tt.current, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSmallDecimals(t *testing.T) {
+	tests := []struct {
+		value, want float64
+	}{
+		{0, 0},
+		{12.3456, 12.35},
+		{0.01, 0.01},
+		{0.0123, 0.01},
+		{0.0029, 0.0029},
+		{0.00294, 0.0029},
+		{0.00005, 0.0001},
+		{0.00004, 0},
+		{-0.0029, -0.0029},
+		{-1.234, -1.23},
+	}
+	for _, tt := range tests {
+		if got := smallDecimals(tt.value); got != tt.want {
+			t.Errorf("smallDecimals(%v) = %v; want %v", tt.value, got, tt.want)
+		}
+	}
+}
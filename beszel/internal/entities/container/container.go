@@ -8,15 +8,15 @@ type ApiInfo struct {
 	IdShort string
 	Names   []string
 	Status  string
-	// Image   string
+	Image   string
 	// ImageID string
 	// Command string
 	// Created int64
 	// Ports      []Port
 	// SizeRw     int64 `json:",omitempty"`
 	// SizeRootFs int64 `json:",omitempty"`
-	// Labels     map[string]string
-	// State      string
+	Labels map[string]string
+	State  string
 	// HostConfig struct {
 	// 	NetworkMode string            `json:",omitempty"`
 	// 	Annotations map[string]string `json:",omitempty"`
@@ -25,6 +25,28 @@ type ApiInfo struct {
 	// Mounts          []MountPoint
 }
 
+// Docker container details from /containers/{id}/json
+type ApiInspect struct {
+	Id    string
+	State struct {
+		Pid        int
+		StartedAt  time.Time
+		FinishedAt time.Time
+		OOMKilled  bool
+	}
+	HostConfig struct {
+		CpuShares   int64
+		CpuQuota    int64
+		CpuPeriod   int64
+		NanoCpus    int64
+		NetworkMode string
+	}
+	Config struct {
+		Image string
+	}
+	RestartCount int
+}
+
 // Docker container resources from /containers/{id}/stats
 type ApiStats struct {
 	// Common stats
@@ -33,7 +55,7 @@ type ApiStats struct {
 
 	// Linux specific stats, not populated on Windows.
 	// PidsStats  PidsStats  `json:"pids_stats,omitempty"`
-	// BlkioStats BlkioStats `json:"blkio_stats,omitempty"`
+	BlkioStats BlkioStats `json:"blkio_stats,omitempty"`
 
 	// Windows specific stats, not populated on Linux.
 	// NumProcs uint32 `json:"num_procs"`
@@ -94,7 +116,7 @@ type MemoryStats struct {
 	// TODO(vishh): Export these as stronger types.
 	// number of times memory usage hits limits.
 	// Failcnt uint64 `json:"failcnt,omitempty"`
-	// Limit   uint64 `json:"limit,omitempty"`
+	Limit uint64 `json:"limit,omitempty"`
 
 	// // committed bytes
 	// Commit uint64 `json:"commitbytes,omitempty"`
@@ -107,6 +129,25 @@ type MemoryStats struct {
 type MemoryStatsStats struct {
 	Cache        uint64 `json:"cache,omitempty"`
 	InactiveFile uint64 `json:"inactive_file,omitempty"`
+	Swap         uint64 `json:"swap,omitempty"`        // cgroup v1 only
+	Rss          uint64 `json:"rss,omitempty"`         // cgroup v1 only
+	MappedFile   uint64 `json:"mapped_file,omitempty"` // cgroup v1 only
+	Anon         uint64 `json:"anon,omitempty"`        // cgroup v2 only
+	File         uint64 `json:"file,omitempty"`        // cgroup v2 only
+	FileMapped   uint64 `json:"file_mapped,omitempty"` // cgroup v2 only
+}
+
+type BlkioStats struct {
+	// Bytes read and written per device and operation. Empty on cgroup v2
+	// hosts where the io controller isn't enabled for the container.
+	IoServiceBytesRecursive []BlkioStatEntry `json:"io_service_bytes_recursive"`
+}
+
+type BlkioStatEntry struct {
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+	Op    string `json:"op"` // "Read" / "Write" on cgroup v1, "read" / "write" on v2
+	Value uint64 `json:"value"`
 }
 
 type NetworkStats struct {
@@ -116,19 +157,77 @@ type NetworkStats struct {
 	TxBytes uint64 `json:"tx_bytes"`
 }
 
+// Configured cpu constraints of a container (zero values are unset)
+type CpuConfig struct {
+	Shares   int64 `json:"s,omitempty"`
+	Quota    int64 `json:"q,omitempty"` // microseconds per period
+	Period   int64 `json:"p,omitempty"` // microseconds
+	NanoCpus int64 `json:"n,omitempty"` // billionths of a cpu
+}
+
+// Container memory split into anonymous and file backed memory (MB)
+type MemDetail struct {
+	Rss    float64 `json:"r"`  // Anonymous memory
+	Cache  float64 `json:"c"`  // Page cache
+	Mapped float64 `json:"mf"` // Page cache mapped into processes
+}
+
+// Cpu and memory of the replicas in a rolled-up service entry
+type ReplicaSpread struct {
+	CpuMin float64 `json:"cn"`
+	CpuMax float64 `json:"cx"`
+	CpuAvg float64 `json:"ca"`
+	MemMin float64 `json:"mn"`
+	MemMax float64 `json:"mx"`
+	MemAvg float64 `json:"ma"`
+}
+
+// Number of TCP sockets in each state in a container's network namespace
+type SocketCounts struct {
+	Established int `json:"e"`
+	Listen      int `json:"l"`
+	TimeWait    int `json:"tw"`
+}
+
 type prevNetStats struct {
 	Sent uint64
 	Recv uint64
 	Time time.Time
 }
 
+type prevDiskStats struct {
+	Read  uint64
+	Write uint64
+	Time  time.Time
+}
+
 // Docker container stats
 type Stats struct {
-	Name        string       `json:"n"`
-	Cpu         float64      `json:"c"`
-	Mem         float64      `json:"m"`
-	NetworkSent float64      `json:"ns"`
-	NetworkRecv float64      `json:"nr"`
-	PrevCpu     [2]uint64    `json:"-"`
-	PrevNet     prevNetStats `json:"-"`
+	Name            string            `json:"n"`
+	Type            string            `json:"t,omitempty"` // "jail" for FreeBSD jails, empty for containers
+	Image           string            `json:"i,omitempty"` // Image name and tag, without digest
+	Health          string            `json:"h,omitempty"` // "healthy", "unhealthy", or "starting", empty without a healthcheck
+	Restarts        int               `json:"r,omitempty"` // Restarts by the restart policy
+	Uptime          uint64            `json:"u,omitempty"` // Seconds since the container started
+	Cpu             float64           `json:"c"`
+	Mem             float64           `json:"m"`
+	MemLimit        float64           `json:"ml,omitempty"` // MB, zero if unlimited
+	MemPct          float64           `json:"mp,omitempty"` // Percent of MemLimit
+	OomKills        int               `json:"om,omitempty"` // Out of memory kills since the last update
+	NetworkSent     float64           `json:"ns"`
+	NetworkRecv     float64           `json:"nr"`
+	DiskReadPs      float64           `json:"dr,omitempty"` // MB/s
+	DiskWritePs     float64           `json:"dw,omitempty"` // MB/s
+	SwapUsed        float64           `json:"su,omitempty"` // MB
+	Labels          map[string]string `json:"l,omitempty"`
+	UpdateAvailable bool              `json:"ua,omitempty"` // Newer image available for the container's tag
+	CpuConfig       *CpuConfig        `json:"cfg,omitempty"`
+	Sockets         *SocketCounts     `json:"sk,omitempty"`
+	MemDetail       *MemDetail        `json:"md,omitempty"`
+	Replicas        int               `json:"rc,omitempty"` // Number of replicas rolled up into this entry
+	Spread          *ReplicaSpread    `json:"rs,omitempty"`
+	LogErrors       float64           `json:"le,omitempty"` // Log lines matching CONTAINER_LOG_PATTERN per minute
+	PrevCpu         [2]uint64         `json:"-"`
+	PrevNet         prevNetStats      `json:"-"`
+	PrevDisk        prevDiskStats     `json:"-"`
 }
@@ -6,29 +6,88 @@ import (
 )
 
 type Stats struct {
-	Cpu            float64             `json:"cpu"`
-	MaxCpu         float64             `json:"cpum,omitempty"`
-	Mem            float64             `json:"m"`
-	MemUsed        float64             `json:"mu"`
-	MemPct         float64             `json:"mp"`
-	MemBuffCache   float64             `json:"mb"`
-	MemZfsArc      float64             `json:"mz,omitempty"` // ZFS ARC memory
-	Swap           float64             `json:"s,omitempty"`
-	SwapUsed       float64             `json:"su,omitempty"`
-	DiskTotal      float64             `json:"d"`
-	DiskUsed       float64             `json:"du"`
-	DiskPct        float64             `json:"dp"`
-	DiskReadPs     float64             `json:"dr"`
-	DiskWritePs    float64             `json:"dw"`
-	MaxDiskReadPs  float64             `json:"drm,omitempty"`
-	MaxDiskWritePs float64             `json:"dwm,omitempty"`
-	NetworkSent    float64             `json:"ns"`
-	NetworkRecv    float64             `json:"nr"`
-	MaxNetworkSent float64             `json:"nsm,omitempty"`
-	MaxNetworkRecv float64             `json:"nrm,omitempty"`
-	Temperatures   map[string]float64  `json:"t,omitempty"`
-	ExtraFs        map[string]*FsStats `json:"efs,omitempty"`
-	GPUData        map[string]GPUData  `json:"g,omitempty"`
+	Cpu               float64               `json:"cpu"`
+	CpuFailed         bool                  `json:"cpuf,omitempty"` // Cpu read failed, value is from the last success
+	MaxCpu            float64               `json:"cpum,omitempty"`
+	CpuPerCore        []float64             `json:"cpc,omitempty"`  // Usage percent of each logical core
+	CpuIowait         float64               `json:"cpuw,omitempty"` // Percent of cpu time waiting on I/O
+	CpuSteal          float64               `json:"cpus,omitempty"` // Percent of cpu time taken by the hypervisor
+	ContextSwitchesPs float64               `json:"cs,omitempty"`
+	InterruptsPs      float64               `json:"in,omitempty"`
+	LoadAvg1          float64               `json:"l1,omitempty"`
+	LoadAvg5          float64               `json:"l5,omitempty"`
+	LoadAvg15         float64               `json:"l15,omitempty"`
+	LoadPerCore       float64               `json:"lpc,omitempty"` // 1 minute load average divided by cores
+	Pressure          *Pressure             `json:"psi,omitempty"` // Pressure stall information (Linux 4.20+)
+	ProcessCount      uint32                `json:"prc,omitempty"`
+	ThreadCount       uint32                `json:"thc,omitempty"`
+	ZombieCount       uint32                `json:"zc,omitempty"`
+	Mem               float64               `json:"m"`
+	MemUsed           float64               `json:"mu"`
+	MemPct            float64               `json:"mp"`
+	MemBuffCache      float64               `json:"mb"`
+	MemZfsArc         float64               `json:"mz,omitempty"`  // ZFS ARC memory
+	MemBandwidth      float64               `json:"mbw,omitempty"` // GB/s
+	MemAnon           float64               `json:"ma,omitempty"`  // Anonymous (process) memory
+	MemPageCache      float64               `json:"mc,omitempty"`  // Page cache, reclaimable
+	MemSlab           float64               `json:"ms,omitempty"`  // Kernel slab
+	MemThrashing      float64               `json:"mth,omitempty"` // Percent of time all tasks stalled on memory (PSI full avg10)
+	Swap              float64               `json:"s,omitempty"`
+	SwapUsed          float64               `json:"su,omitempty"`
+	SwapInPs          float64               `json:"si,omitempty"` // MB/s read from swap
+	SwapOutPs         float64               `json:"so,omitempty"` // MB/s written to swap
+	DiskTotal         float64               `json:"d"`
+	DiskUsed          float64               `json:"du"`
+	DiskPct           float64               `json:"dp"`
+	DiskReadPs        float64               `json:"dr"`
+	DiskWritePs       float64               `json:"dw"`
+	DiskUtil          float64               `json:"dut,omitempty"` // Percent of time with I/O in flight
+	DiskReadOpsPs     float64               `json:"dro,omitempty"` // Root disk read operations per second
+	DiskWriteOpsPs    float64               `json:"dwo,omitempty"` // Root disk write operations per second
+	DiskAwait         float64               `json:"daw,omitempty"` // Root disk average ms per operation
+	DiskErrors        uint64                `json:"de,omitempty"`  // Root filesystem error count
+	DiskType          string                `json:"dt,omitempty"`  // Root disk type ("ssd" or "hdd")
+	DiskTransport     string                `json:"dtr,omitempty"` // Root disk transport
+	DiskLatencyP99    float64               `json:"dlt,omitempty"` // Root disk p99 I/O latency (ms)
+	InodesPct         float64               `json:"ip,omitempty"`  // Root filesystem inode usage
+	DiskFstype        string                `json:"dfs,omitempty"` // Root filesystem type
+	MaxDiskReadPs     float64               `json:"drm,omitempty"`
+	MaxDiskWritePs    float64               `json:"dwm,omitempty"`
+	NetworkSent       float64               `json:"ns"`
+	NetworkRecv       float64               `json:"nr"`
+	MaxNetworkSent    float64               `json:"nsm,omitempty"`
+	MaxNetworkRecv    float64               `json:"nrm,omitempty"`
+	NetworkErrorsPs   float64               `json:"ne,omitempty"`  // Interface errors per second
+	NetworkDropsPs    float64               `json:"nd,omitempty"`  // Dropped packets per second
+	Connections       map[string]int        `json:"cn,omitempty"`  // TCP connections by state, plus udp sockets
+	ConntrackCount    uint64                `json:"ctc,omitempty"` // Tracked connections
+	ConntrackMax      uint64                `json:"ctm,omitempty"` // Size of the conntrack table
+	ConntrackPct      float64               `json:"ctp,omitempty"`
+	Temperatures      map[string]float64    `json:"t,omitempty"`
+	Fans              map[string]float64    `json:"fan,omitempty"` // Fan speeds in RPM
+	ExtraFs           map[string]*FsStats   `json:"efs,omitempty"`
+	GPUData           map[string]GPUData    `json:"g,omitempty"`
+	NetNs             map[string]NetNsStats `json:"nn,omitempty"`  // Network namespace bandwidth
+	Interfaces        map[string]NetIfStats `json:"nis,omitempty"` // Bandwidth of each network interface
+	Custom            map[string]float64    `json:"cm,omitempty"`
+	Ipmi              map[string]IpmiSensor `json:"ipmi,omitempty"`
+}
+
+// Pressure stall information avg10 values: the percent of the last 10 seconds
+// in which some or all non-idle tasks were stalled waiting on each resource
+type Pressure struct {
+	CpuSome float64 `json:"cs"`
+	CpuFull float64 `json:"cf,omitempty"`
+	MemSome float64 `json:"ms"`
+	MemFull float64 `json:"mf"`
+	IoSome  float64 `json:"is"`
+	IoFull  float64 `json:"if"`
+}
+
+type IpmiSensor struct {
+	Value  float64 `json:"v"`
+	Unit   string  `json:"u,omitempty"`
+	Status string  `json:"s"`
 }
 
 type GPUData struct {
@@ -53,28 +112,178 @@ type FsStats struct {
 	DiskWritePs    float64   `json:"w"`
 	MaxDiskReadPS  float64   `json:"rm,omitempty"`
 	MaxDiskWritePS float64   `json:"wm,omitempty"`
+	TimedOut       bool      `json:"to,omitempty"` // Usage query timed out, values are from last success
+	TotalIoTime    uint64    `json:"-"`
+	DiskUtil       float64   `json:"ut,omitempty"` // Percent of time with I/O in flight
+	TotalReadOps   uint64    `json:"-"`
+	TotalWriteOps  uint64    `json:"-"`
+	DiskReadOpsPs  float64   `json:"ro,omitempty"` // Read operations per second
+	DiskWriteOpsPs float64   `json:"wo,omitempty"` // Write operations per second
+	TotalOpTime    uint64    `json:"-"`            // Milliseconds spent on reads and writes
+	DiskAwait      float64   `json:"aw,omitempty"` // Average ms per operation, including queue time
+	FsErrors       uint64    `json:"fe,omitempty"` // Errors recorded by the filesystem since mount
+	DiskType       string    `json:"dt,omitempty"` // "ssd" or "hdd"
+	Transport      string    `json:"tr,omitempty"` // e.g. "nvme", "sata", "usb"
+	LatencyP99     float64   `json:"lt,omitempty"` // p99 I/O latency (ms) of the disk
+	Fstype         string    `json:"fs,omitempty"` // e.g. "ext4", "zfs", "nfs4"
+	InodesTotal    uint64    `json:"it,omitempty"`
+	InodesUsed     uint64    `json:"iu,omitempty"`
+	InodesPct      float64   `json:"ip,omitempty"`
+}
+
+type NetNsStats struct {
+	NetworkSent float64 `json:"ns"`
+	NetworkRecv float64 `json:"nr"`
+}
+
+type NetIfStats struct {
+	NetworkSent float64 `json:"ns"`
+	NetworkRecv float64 `json:"nr"`
 }
 
 type NetIoStats struct {
 	BytesRecv uint64
 	BytesSent uint64
+	Errors    uint64 // Receive and transmit errors
+	Drops     uint64 // Receive and transmit drops
 	Time      time.Time
 	Name      string
 }
 
 type Info struct {
-	Hostname      string  `json:"h"`
-	KernelVersion string  `json:"k,omitempty"`
-	Cores         int     `json:"c"`
-	Threads       int     `json:"t,omitempty"`
-	CpuModel      string  `json:"m"`
-	Uptime        uint64  `json:"u"`
-	Cpu           float64 `json:"cpu"`
-	MemPct        float64 `json:"mp"`
-	DiskPct       float64 `json:"dp"`
-	Bandwidth     float64 `json:"b"`
-	AgentVersion  string  `json:"v"`
-	Podman        bool    `json:"p,omitempty"`
+	Hostname      string                  `json:"h"`
+	KernelVersion string                  `json:"k,omitempty"`
+	KernelCmdline string                  `json:"kc,omitempty"` // Selected boot parameters (KERNEL_CMDLINE)
+	Cores         int                     `json:"c"`
+	Threads       int                     `json:"t,omitempty"`
+	CpuModel      string                  `json:"m"`
+	CpuFreq       float64                 `json:"f,omitempty"` // MHz, averaged across cpus
+	Uptime        uint64                  `json:"u"`
+	BootTime      int64                   `json:"bt,omitempty"` // Unix time the host booted
+	Cpu           float64                 `json:"cpu"`
+	MemPct        float64                 `json:"mp"`
+	DiskPct       float64                 `json:"dp"`
+	Bandwidth     float64                 `json:"b"`
+	AgentVersion  string                  `json:"v"`
+	Podman        bool                    `json:"p,omitempty"`
+	TimeZone      string                  `json:"tz,omitempty"`
+	Locale        string                  `json:"lc,omitempty"`
+	Os            string                  `json:"os,omitempty"`   // Distribution or platform, e.g. "ubuntu" or "darwin"
+	OsVersion     string                  `json:"osv,omitempty"`  // e.g. "24.04"
+	Arch          string                  `json:"arch,omitempty"` // Machine hardware name, e.g. "x86_64"
+	AgentRuntime  *AgentRuntime           `json:"ar,omitempty"`
+	Containers    *ContainerStates        `json:"cs,omitempty"`
+	Users         []UserStats             `json:"us,omitempty"`
+	Dns           *DnsStatus              `json:"dns,omitempty"`
+	Updated       map[string]int64        `json:"up,omitempty"` // Unix time each cached section was collected
+	Stale         []string                `json:"st,omitempty"` // Cached sections older than STALE_THRESHOLD
+	DockerDisk    *DockerDiskUsage        `json:"dd,omitempty"`
+	CpuTopology   *CpuTopology            `json:"ct,omitempty"`
+	PublicIP      string                  `json:"pip,omitempty"`
+	Docker        *DockerStatus           `json:"ds,omitempty"`
+	PowerCap      *PowerCap               `json:"pc,omitempty"`
+	SystemState   string                  `json:"ss,omitempty"` // systemd state, e.g. "running" or "degraded"
+	DiskHealth    map[string]DiskHealth   `json:"dh,omitempty"` // SMART health by device name
+	NetInterfaces map[string]NetInterface `json:"ni,omitempty"`
+	Battery       *Battery                `json:"bat,omitempty"`
+	Processes     []ProcessStats          `json:"tp,omitempty"` // Top processes by cpu and memory
+}
+
+// Combined charge of the system's batteries
+type Battery struct {
+	Percent     float64 `json:"p"`
+	Charging    bool    `json:"c,omitempty"`
+	TimeToEmpty uint32  `json:"tte,omitempty"` // Minutes remaining while discharging
+}
+
+// Link settings of a monitored network interface
+type NetInterface struct {
+	Mtu    int    `json:"mtu"`
+	Duplex string `json:"dx,omitempty"` // "full" or "half"
+	Speed  int    `json:"sp,omitempty"` // Negotiated link speed in Mbps
+	Up     bool   `json:"up"`
+}
+
+// Active RAPL package power limits in watts
+type PowerCap struct {
+	LongTerm  float64 `json:"l,omitempty"` // PL1, sustained limit
+	ShortTerm float64 `json:"s,omitempty"` // PL2, burst limit
+}
+
+// SMART health of a drive
+type DiskHealth struct {
+	Passed      bool    `json:"ok"`
+	Reallocated uint64  `json:"rs,omitempty"` // Reallocated sectors
+	Pending     uint64  `json:"ps,omitempty"` // Sectors pending reallocation
+	Temperature float64 `json:"t,omitempty"`
+}
+
+// Whether the Docker API is reachable
+type DockerStatus struct {
+	Available bool   `json:"a"`
+	Error     string `json:"e,omitempty"` // Last error, kept until the next success
+}
+
+// Static cpu layout and cache sizes (KB) from sysfs
+type CpuTopology struct {
+	Sockets        int `json:"s"`
+	CoresPerSocket int `json:"cps"`
+	ThreadsPerCore int `json:"tpc"`
+	L1d            int `json:"l1d,omitempty"`
+	L1i            int `json:"l1i,omitempty"`
+	L2             int `json:"l2,omitempty"`
+	L3             int `json:"l3,omitempty"`
+}
+
+// Disk space used by Docker, in GB
+type DockerDiskUsage struct {
+	Images         float64             `json:"i"`
+	Containers     float64             `json:"c"` // Writable layers
+	Volumes        float64             `json:"v"`
+	BuildCache     float64             `json:"b"`
+	LargestVolumes []DockerVolumeUsage `json:"lv,omitempty"`
+}
+
+type DockerVolumeUsage struct {
+	Name string  `json:"n"`
+	Size float64 `json:"s"`
+}
+
+// Result of the most recent DNS resolution probe
+type DnsStatus struct {
+	Ok      bool    `json:"ok"`
+	Latency float64 `json:"l"` // ms
+	Error   string  `json:"e,omitempty"`
+}
+
+// Resource usage of all processes owned by a user
+type ProcessStats struct {
+	Name string  `json:"n"`
+	Pid  int32   `json:"p"`
+	Cpu  float64 `json:"c"` // percent of total host cpu
+	Mem  float64 `json:"m"` // MB
+}
+
+type UserStats struct {
+	Name  string  `json:"n"`
+	Cpu   float64 `json:"c"` // percent of total host cpu
+	Mem   float64 `json:"m"` // MB
+	Procs int     `json:"p"`
+}
+
+// Number of containers in each state
+type ContainerStates struct {
+	Running    int `json:"r"`
+	Stopped    int `json:"s"` // created, exited, or dead
+	Paused     int `json:"p"`
+	Restarting int `json:"rs"`
+}
+
+// Resource usage of the agent process itself
+type AgentRuntime struct {
+	Goroutines int     `json:"g"`
+	HeapAlloc  float64 `json:"h"` // MB
+	NumGC      uint32  `json:"gc"`
 }
 
 // Final data structure to return to the hub
@@ -82,4 +291,29 @@ type CombinedData struct {
 	Stats      Stats              `json:"stats"`
 	Info       Info               `json:"info"`
 	Containers []*container.Stats `json:"container"`
+	Events     []Event            `json:"ev,omitempty"`
+	VMs        []VmStats          `json:"vms,omitempty"`
+}
+
+// Virtual machine stats, parallel to container.Stats
+type VmStats struct {
+	Name  string  `json:"n"`
+	Cpu   float64 `json:"c"` // percent of total host cpu
+	Mem   float64 `json:"m"` // MB
+	Vcpus int     `json:"v,omitempty"`
+}
+
+// A change in the value of an event-like field since the previous update
+type Event struct {
+	Time  int64  `json:"t"` // Unix time the change was detected
+	Name  string `json:"n"`
+	Prev  string `json:"p"`
+	Value string `json:"v"`
+}
+
+// Stats relayed from a remote agent, tagged with the remote's configured name
+type RemoteData struct {
+	Name  string        `json:"n"`
+	Data  *CombinedData `json:"d,omitempty"`
+	Error string        `json:"e,omitempty"`
 }
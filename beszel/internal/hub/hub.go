@@ -12,6 +12,7 @@ import (
 	"context"
 	"crypto/ed25519"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -277,7 +278,13 @@ func (h *Hub) updateSystem(record *models.Record) {
 	}
 	// get system stats from agent
 	var systemData system.CombinedData
-	if err := h.requestJsonFromAgent(client, &systemData); err != nil {
+	// the host was validated when the connection was created
+	if remote, _, _ := splitRemoteHost(record.GetString("host")); remote != "" {
+		err = h.requestRemoteFromAgent(client, remote, &systemData)
+	} else {
+		err = h.requestJsonFromAgent(client, &systemData)
+	}
+	if err != nil {
 		if err.Error() == "bad client" {
 			// if previous connection was closed, try again
 			h.app.Logger().Error("Existing SSH connection closed. Retrying...", "host", record.GetString("host"), "port", record.GetString("port"))
@@ -367,11 +374,15 @@ func (h *Hub) deleteSystemConnection(record *models.Record) {
 }
 
 func (h *Hub) createSystemConnection(record *models.Record) (*ssh.Client, error) {
-        client, err := ssh.Dial("tcp", net.JoinHostPort(record.GetString("host"), record.GetString("port")), h.sshClientConfig)
-        if err != nil {
-                return nil, err
-        }
-        return client, nil
+	_, host, err := splitRemoteHost(record.GetString("host"))
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host, record.GetString("port")), h.sshClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
 func (h *Hub) createSSHClientConfig() error {
@@ -427,6 +438,53 @@ func (h *Hub) requestJsonFromAgent(client *ssh.Client, systemData *system.Combin
 	return nil
 }
 
+// Prefix of system hosts that refer to a remote relayed by an agent
+const remoteHostPrefix = "remote:"
+
+// Splits a system host of the form "remote:<name>@<host>" into the name of a
+// remote relayed by the agent at host and the host itself. Remote is empty for
+// regular hosts.
+func splitRemoteHost(systemHost string) (remote, host string, err error) {
+	rest, found := strings.CutPrefix(systemHost, remoteHostPrefix)
+	if !found {
+		return "", systemHost, nil
+	}
+	remote, host, found = strings.Cut(rest, "@")
+	if !found || remote == "" || host == "" {
+		return "", "", fmt.Errorf("invalid remote host %q, expected %s<name>@<host>", systemHost, remoteHostPrefix)
+	}
+	return remote, host, nil
+}
+
+// Fetches stats of a remote relayed by the agent and decodes them into the provided struct
+func (h *Hub) requestRemoteFromAgent(client *ssh.Client, remote string, systemData *system.CombinedData) error {
+	session, err := newSessionWithTimeout(client, 4*time.Second)
+	if err != nil {
+		return fmt.Errorf("bad client")
+	}
+	defer session.Close()
+
+	output, err := session.Output("remote " + remote)
+	if err != nil {
+		return err
+	}
+	var remoteData system.RemoteData
+	if err := json.Unmarshal(output, &remoteData); err != nil {
+		return err
+	}
+	if remoteData.Name != remote {
+		return fmt.Errorf("relay returned %q instead of %q", remoteData.Name, remote)
+	}
+	if remoteData.Error != "" {
+		return errors.New(remoteData.Error)
+	}
+	if remoteData.Data == nil {
+		return fmt.Errorf("no data for remote %q", remote)
+	}
+	*systemData = *remoteData.Data
+	return nil
+}
+
 // Adds timeout to SSH session creation to avoid hanging in case of network issues
 func newSessionWithTimeout(client *ssh.Client, timeout time.Duration) (*ssh.Session, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
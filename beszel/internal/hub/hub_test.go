@@ -0,0 +1,25 @@
+package hub
+
+import "testing"
+
+func TestSplitRemoteHost(t *testing.T) {
+	tests := []struct {
+		systemHost string
+		remote     string
+		host       string
+		wantErr    bool
+	}{
+		{"192.168.1.10", "", "192.168.1.10", false},
+		{"user@example.com", "", "user@example.com", false},
+		{"remote:nas@192.168.1.10", "nas", "192.168.1.10", false},
+		{"remote:@192.168.1.10", "", "", true},
+		{"remote:nas@", "", "", true},
+		{"remote:nas", "", "", true},
+	}
+	for _, tt := range tests {
+		remote, host, err := splitRemoteHost(tt.systemHost)
+		if remote != tt.remote || host != tt.host || (err != nil) != tt.wantErr {
+			t.Errorf("splitRemoteHost(%q) = %q, %q, %v; want %q, %q, error %v", tt.systemHost, remote, host, err, tt.remote, tt.host, tt.wantErr)
+		}
+	}
+}
@@ -153,9 +153,18 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 	count := float64(len(records))
 	// use different counter for temps in case some records don't have them
 	tempCount := float64(0)
+	fanCount := float64(0)
+	pressureCount := float64(0)
+	// metrics may be missing from some records if a read failed
+	var customCount map[string]float64
+	var interfaceCount map[string]float64
+	var connCount map[string]float64
+	var perCoreCount []float64
 
 	var stats system.Stats
 	for i := range records {
+		// reset so fields and maps a record omits don't keep the previous record's values
+		stats = system.Stats{}
 		json.Unmarshal(records[i].Stats, &stats)
 		sum.Cpu += stats.Cpu
 		sum.Mem += stats.Mem
@@ -163,15 +172,59 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 		sum.MemPct += stats.MemPct
 		sum.MemBuffCache += stats.MemBuffCache
 		sum.MemZfsArc += stats.MemZfsArc
+		sum.MemAnon += stats.MemAnon
+		sum.MemPageCache += stats.MemPageCache
+		sum.MemSlab += stats.MemSlab
+		sum.MemThrashing += stats.MemThrashing
+		sum.CpuIowait += stats.CpuIowait
+		sum.CpuSteal += stats.CpuSteal
+		sum.ContextSwitchesPs += stats.ContextSwitchesPs
+		sum.InterruptsPs += stats.InterruptsPs
+		sum.LoadAvg1 += stats.LoadAvg1
+		sum.LoadAvg5 += stats.LoadAvg5
+		sum.LoadAvg15 += stats.LoadAvg15
+		sum.LoadPerCore += stats.LoadPerCore
+		sum.ProcessCount += stats.ProcessCount
+		sum.ThreadCount += stats.ThreadCount
+		// keep the peak so short-lived zombie spikes aren't averaged away
+		sum.ZombieCount = max(sum.ZombieCount, stats.ZombieCount)
+		// per core usage, averaged over the records that have each core
+		for i, pct := range stats.CpuPerCore {
+			if i == len(sum.CpuPerCore) {
+				sum.CpuPerCore = append(sum.CpuPerCore, 0)
+				perCoreCount = append(perCoreCount, 0)
+			}
+			sum.CpuPerCore[i] += pct
+			perCoreCount[i]++
+		}
 		sum.Swap += stats.Swap
 		sum.SwapUsed += stats.SwapUsed
+		sum.SwapInPs += stats.SwapInPs
+		sum.SwapOutPs += stats.SwapOutPs
 		sum.DiskTotal += stats.DiskTotal
 		sum.DiskUsed += stats.DiskUsed
 		sum.DiskPct += stats.DiskPct
 		sum.DiskReadPs += stats.DiskReadPs
 		sum.DiskWritePs += stats.DiskWritePs
+		sum.DiskReadOpsPs += stats.DiskReadOpsPs
+		sum.DiskWriteOpsPs += stats.DiskWriteOpsPs
+		sum.DiskAwait += stats.DiskAwait
 		sum.NetworkSent += stats.NetworkSent
 		sum.NetworkRecv += stats.NetworkRecv
+		sum.NetworkErrorsPs += stats.NetworkErrorsPs
+		sum.NetworkDropsPs += stats.NetworkDropsPs
+		sum.ConntrackCount += stats.ConntrackCount
+		sum.ConntrackPct += stats.ConntrackPct
+		// table size only changes through sysctl, so keep the latest
+		sum.ConntrackMax = stats.ConntrackMax
+		// error counts only grow, so keep the latest
+		sum.DiskErrors = max(sum.DiskErrors, stats.DiskErrors)
+		sum.DiskType = stats.DiskType
+		// keep the worst tail latency
+		sum.DiskLatencyP99 = max(sum.DiskLatencyP99, stats.DiskLatencyP99)
+		sum.InodesPct += stats.InodesPct
+		sum.DiskTransport = stats.DiskTransport
+		sum.DiskFstype = stats.DiskFstype
 		// set peak values
 		sum.MaxCpu = max(sum.MaxCpu, stats.MaxCpu, stats.Cpu)
 		sum.MaxNetworkSent = max(sum.MaxNetworkSent, stats.MaxNetworkSent, stats.NetworkSent)
@@ -191,6 +244,65 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 				sum.Temperatures[key] += value
 			}
 		}
+		// add fans to sum
+		if stats.Fans != nil {
+			if sum.Fans == nil {
+				sum.Fans = make(map[string]float64, len(stats.Fans))
+			}
+			fanCount++
+			for key, value := range stats.Fans {
+				sum.Fans[key] += value
+			}
+		}
+		// add pressure to sum
+		if stats.Pressure != nil {
+			if sum.Pressure == nil {
+				sum.Pressure = &system.Pressure{}
+			}
+			pressureCount++
+			sum.Pressure.CpuSome += stats.Pressure.CpuSome
+			sum.Pressure.CpuFull += stats.Pressure.CpuFull
+			sum.Pressure.MemSome += stats.Pressure.MemSome
+			sum.Pressure.MemFull += stats.Pressure.MemFull
+			sum.Pressure.IoSome += stats.Pressure.IoSome
+			sum.Pressure.IoFull += stats.Pressure.IoFull
+		}
+		// add custom metrics to sum
+		if stats.Custom != nil {
+			if sum.Custom == nil {
+				sum.Custom = make(map[string]float64, len(stats.Custom))
+				customCount = make(map[string]float64, len(stats.Custom))
+			}
+			for key, value := range stats.Custom {
+				sum.Custom[key] += value
+				customCount[key]++
+			}
+		}
+		// add connections to sum
+		if stats.Connections != nil {
+			if sum.Connections == nil {
+				sum.Connections = make(map[string]int, len(stats.Connections))
+				connCount = make(map[string]float64, len(stats.Connections))
+			}
+			for state, value := range stats.Connections {
+				sum.Connections[state] += value
+				connCount[state]++
+			}
+		}
+		// add network interfaces to sum
+		if stats.Interfaces != nil {
+			if sum.Interfaces == nil {
+				sum.Interfaces = make(map[string]system.NetIfStats, len(stats.Interfaces))
+				interfaceCount = make(map[string]float64, len(stats.Interfaces))
+			}
+			for name, value := range stats.Interfaces {
+				iface := sum.Interfaces[name]
+				iface.NetworkSent += value.NetworkSent
+				iface.NetworkRecv += value.NetworkRecv
+				sum.Interfaces[name] = iface
+				interfaceCount[name]++
+			}
+		}
 		// add extra fs to sum
 		if stats.ExtraFs != nil {
 			if sum.ExtraFs == nil {
@@ -204,6 +316,17 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 				sum.ExtraFs[key].DiskUsed += value.DiskUsed
 				sum.ExtraFs[key].DiskWritePs += value.DiskWritePs
 				sum.ExtraFs[key].DiskReadPs += value.DiskReadPs
+				sum.ExtraFs[key].DiskReadOpsPs += value.DiskReadOpsPs
+				sum.ExtraFs[key].DiskWriteOpsPs += value.DiskWriteOpsPs
+				sum.ExtraFs[key].DiskAwait += value.DiskAwait
+				sum.ExtraFs[key].FsErrors = max(sum.ExtraFs[key].FsErrors, value.FsErrors)
+				sum.ExtraFs[key].DiskType = value.DiskType
+				sum.ExtraFs[key].Transport = value.Transport
+				sum.ExtraFs[key].Fstype = value.Fstype
+				sum.ExtraFs[key].LatencyP99 = max(sum.ExtraFs[key].LatencyP99, value.LatencyP99)
+				sum.ExtraFs[key].InodesTotal = value.InodesTotal
+				sum.ExtraFs[key].InodesUsed = value.InodesUsed
+				sum.ExtraFs[key].InodesPct += value.InodesPct
 				// peak values
 				sum.ExtraFs[key].MaxDiskReadPS = max(sum.ExtraFs[key].MaxDiskReadPS, value.MaxDiskReadPS, value.DiskReadPs)
 				sum.ExtraFs[key].MaxDiskWritePS = max(sum.ExtraFs[key].MaxDiskWritePS, value.MaxDiskWritePS, value.DiskWritePs)
@@ -231,26 +354,57 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 	}
 
 	stats = system.Stats{
-		Cpu:            twoDecimals(sum.Cpu / count),
-		Mem:            twoDecimals(sum.Mem / count),
-		MemUsed:        twoDecimals(sum.MemUsed / count),
-		MemPct:         twoDecimals(sum.MemPct / count),
-		MemBuffCache:   twoDecimals(sum.MemBuffCache / count),
-		MemZfsArc:      twoDecimals(sum.MemZfsArc / count),
-		Swap:           twoDecimals(sum.Swap / count),
-		SwapUsed:       twoDecimals(sum.SwapUsed / count),
-		DiskTotal:      twoDecimals(sum.DiskTotal / count),
-		DiskUsed:       twoDecimals(sum.DiskUsed / count),
-		DiskPct:        twoDecimals(sum.DiskPct / count),
-		DiskReadPs:     twoDecimals(sum.DiskReadPs / count),
-		DiskWritePs:    twoDecimals(sum.DiskWritePs / count),
-		NetworkSent:    twoDecimals(sum.NetworkSent / count),
-		NetworkRecv:    twoDecimals(sum.NetworkRecv / count),
-		MaxCpu:         sum.MaxCpu,
-		MaxDiskReadPs:  sum.MaxDiskReadPs,
-		MaxDiskWritePs: sum.MaxDiskWritePs,
-		MaxNetworkSent: sum.MaxNetworkSent,
-		MaxNetworkRecv: sum.MaxNetworkRecv,
+		Cpu:               twoDecimals(sum.Cpu / count),
+		Mem:               twoDecimals(sum.Mem / count),
+		MemUsed:           twoDecimals(sum.MemUsed / count),
+		MemPct:            twoDecimals(sum.MemPct / count),
+		MemBuffCache:      twoDecimals(sum.MemBuffCache / count),
+		MemZfsArc:         twoDecimals(sum.MemZfsArc / count),
+		MemAnon:           twoDecimals(sum.MemAnon / count),
+		MemPageCache:      twoDecimals(sum.MemPageCache / count),
+		MemSlab:           twoDecimals(sum.MemSlab / count),
+		MemThrashing:      twoDecimals(sum.MemThrashing / count),
+		CpuIowait:         twoDecimals(sum.CpuIowait / count),
+		CpuSteal:          twoDecimals(sum.CpuSteal / count),
+		ContextSwitchesPs: twoDecimals(sum.ContextSwitchesPs / count),
+		InterruptsPs:      twoDecimals(sum.InterruptsPs / count),
+		LoadAvg1:          twoDecimals(sum.LoadAvg1 / count),
+		LoadAvg5:          twoDecimals(sum.LoadAvg5 / count),
+		LoadAvg15:         twoDecimals(sum.LoadAvg15 / count),
+		LoadPerCore:       twoDecimals(sum.LoadPerCore / count),
+		ProcessCount:      uint32(float64(sum.ProcessCount) / count),
+		ThreadCount:       uint32(float64(sum.ThreadCount) / count),
+		ZombieCount:       sum.ZombieCount,
+		Swap:              twoDecimals(sum.Swap / count),
+		SwapUsed:          twoDecimals(sum.SwapUsed / count),
+		SwapInPs:          smallDecimals(sum.SwapInPs / count),
+		SwapOutPs:         smallDecimals(sum.SwapOutPs / count),
+		DiskTotal:         twoDecimals(sum.DiskTotal / count),
+		DiskUsed:          twoDecimals(sum.DiskUsed / count),
+		DiskPct:           twoDecimals(sum.DiskPct / count),
+		DiskReadPs:        twoDecimals(sum.DiskReadPs / count),
+		DiskWritePs:       twoDecimals(sum.DiskWritePs / count),
+		DiskReadOpsPs:     twoDecimals(sum.DiskReadOpsPs / count),
+		DiskWriteOpsPs:    twoDecimals(sum.DiskWriteOpsPs / count),
+		DiskAwait:         twoDecimals(sum.DiskAwait / count),
+		NetworkSent:       twoDecimals(sum.NetworkSent / count),
+		NetworkRecv:       twoDecimals(sum.NetworkRecv / count),
+		NetworkErrorsPs:   twoDecimals(sum.NetworkErrorsPs / count),
+		NetworkDropsPs:    twoDecimals(sum.NetworkDropsPs / count),
+		MaxCpu:            sum.MaxCpu,
+		MaxDiskReadPs:     sum.MaxDiskReadPs,
+		MaxDiskWritePs:    sum.MaxDiskWritePs,
+		MaxNetworkSent:    sum.MaxNetworkSent,
+		MaxNetworkRecv:    sum.MaxNetworkRecv,
+		DiskErrors:        sum.DiskErrors,
+		DiskType:          sum.DiskType,
+		DiskTransport:     sum.DiskTransport,
+		DiskFstype:        sum.DiskFstype,
+		DiskLatencyP99:    sum.DiskLatencyP99,
+		InodesPct:         twoDecimals(sum.InodesPct / count),
+		ConntrackCount:    uint64(float64(sum.ConntrackCount) / count),
+		ConntrackMax:      sum.ConntrackMax,
+		ConntrackPct:      twoDecimals(sum.ConntrackPct / count),
 	}
 
 	if sum.Temperatures != nil {
@@ -260,6 +414,55 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 		}
 	}
 
+	if sum.Fans != nil {
+		stats.Fans = make(map[string]float64, len(sum.Fans))
+		for key, value := range sum.Fans {
+			stats.Fans[key] = math.Round(value / fanCount)
+		}
+	}
+
+	if sum.Pressure != nil {
+		stats.Pressure = &system.Pressure{
+			CpuSome: twoDecimals(sum.Pressure.CpuSome / pressureCount),
+			CpuFull: twoDecimals(sum.Pressure.CpuFull / pressureCount),
+			MemSome: twoDecimals(sum.Pressure.MemSome / pressureCount),
+			MemFull: twoDecimals(sum.Pressure.MemFull / pressureCount),
+			IoSome:  twoDecimals(sum.Pressure.IoSome / pressureCount),
+			IoFull:  twoDecimals(sum.Pressure.IoFull / pressureCount),
+		}
+	}
+
+	if sum.CpuPerCore != nil {
+		stats.CpuPerCore = make([]float64, len(sum.CpuPerCore))
+		for i, value := range sum.CpuPerCore {
+			stats.CpuPerCore[i] = twoDecimals(value / perCoreCount[i])
+		}
+	}
+
+	if sum.Custom != nil {
+		stats.Custom = make(map[string]float64, len(sum.Custom))
+		for key, value := range sum.Custom {
+			stats.Custom[key] = twoDecimals(value / customCount[key])
+		}
+	}
+
+	if sum.Connections != nil {
+		stats.Connections = make(map[string]int, len(sum.Connections))
+		for state, value := range sum.Connections {
+			stats.Connections[state] = int(math.Round(float64(value) / connCount[state]))
+		}
+	}
+
+	if sum.Interfaces != nil {
+		stats.Interfaces = make(map[string]system.NetIfStats, len(sum.Interfaces))
+		for name, value := range sum.Interfaces {
+			stats.Interfaces[name] = system.NetIfStats{
+				NetworkSent: twoDecimals(value.NetworkSent / interfaceCount[name]),
+				NetworkRecv: twoDecimals(value.NetworkRecv / interfaceCount[name]),
+			}
+		}
+	}
+
 	if sum.ExtraFs != nil {
 		stats.ExtraFs = make(map[string]*system.FsStats, len(sum.ExtraFs))
 		for key, value := range sum.ExtraFs {
@@ -268,8 +471,19 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 				DiskUsed:       twoDecimals(value.DiskUsed / count),
 				DiskWritePs:    twoDecimals(value.DiskWritePs / count),
 				DiskReadPs:     twoDecimals(value.DiskReadPs / count),
+				DiskReadOpsPs:  twoDecimals(value.DiskReadOpsPs / count),
+				DiskWriteOpsPs: twoDecimals(value.DiskWriteOpsPs / count),
+				DiskAwait:      twoDecimals(value.DiskAwait / count),
 				MaxDiskReadPS:  value.MaxDiskReadPS,
 				MaxDiskWritePS: value.MaxDiskWritePS,
+				FsErrors:       value.FsErrors,
+				DiskType:       value.DiskType,
+				Transport:      value.Transport,
+				Fstype:         value.Fstype,
+				LatencyP99:     value.LatencyP99,
+				InodesTotal:    value.InodesTotal,
+				InodesUsed:     value.InodesUsed,
+				InodesPct:      twoDecimals(value.InodesPct / count),
 			}
 		}
 	}
@@ -295,35 +509,97 @@ func (rm *RecordManager) AverageSystemStats(records RecordStats) system.Stats {
 // Calculate the average stats of a list of container_stats records
 func (rm *RecordManager) AverageContainerStats(records RecordStats) []container.Stats {
 	sums := make(map[string]*container.Stats)
+	memDetailCounts := make(map[string]float64)
 	count := float64(len(records))
 
-	var containerStats []container.Stats
 	for i := range records {
-		// Reset the slice length to 0, but keep the capacity
-		containerStats = containerStats[:0]
+		// decode into a new slice, since reused elements would keep fields
+		// (e.g. OomKills) that the record omits
+		var containerStats []container.Stats
 		if err := json.Unmarshal(records[i].Stats, &containerStats); err != nil {
 			return []container.Stats{}
 		}
 		for i := range containerStats {
 			stat := containerStats[i]
 			if _, ok := sums[stat.Name]; !ok {
-				sums[stat.Name] = &container.Stats{Name: stat.Name}
+				sums[stat.Name] = &container.Stats{Name: stat.Name, Type: stat.Type}
 			}
 			sums[stat.Name].Cpu += stat.Cpu
 			sums[stat.Name].Mem += stat.Mem
+			sums[stat.Name].MemPct += stat.MemPct
 			sums[stat.Name].NetworkSent += stat.NetworkSent
 			sums[stat.Name].NetworkRecv += stat.NetworkRecv
+			sums[stat.Name].DiskReadPs += stat.DiskReadPs
+			sums[stat.Name].DiskWritePs += stat.DiskWritePs
+			sums[stat.Name].SwapUsed += stat.SwapUsed
+			sums[stat.Name].LogErrors += stat.LogErrors
+			sums[stat.Name].OomKills += stat.OomKills
+			// keep labels, image, memory limit, health, restarts, uptime, config, socket counts, and replicas from the most recent record
+			if stat.Labels != nil {
+				sums[stat.Name].Labels = stat.Labels
+			}
+			sums[stat.Name].Image = stat.Image
+			sums[stat.Name].MemLimit = stat.MemLimit
+			sums[stat.Name].Health = stat.Health
+			sums[stat.Name].Restarts = stat.Restarts
+			sums[stat.Name].Uptime = stat.Uptime
+			if stat.CpuConfig != nil {
+				sums[stat.Name].CpuConfig = stat.CpuConfig
+			}
+			if stat.Sockets != nil {
+				sums[stat.Name].Sockets = stat.Sockets
+			}
+			if stat.Spread != nil {
+				sums[stat.Name].Replicas = stat.Replicas
+				sums[stat.Name].Spread = stat.Spread
+			}
+			// memory breakdown is averaged over the records that have it
+			if stat.MemDetail != nil {
+				if sums[stat.Name].MemDetail == nil {
+					sums[stat.Name].MemDetail = &container.MemDetail{}
+				}
+				sums[stat.Name].MemDetail.Rss += stat.MemDetail.Rss
+				sums[stat.Name].MemDetail.Cache += stat.MemDetail.Cache
+				sums[stat.Name].MemDetail.Mapped += stat.MemDetail.Mapped
+				memDetailCounts[stat.Name]++
+			}
 		}
 	}
 
 	result := make([]container.Stats, 0, len(sums))
 	for _, value := range sums {
+		if md := value.MemDetail; md != nil {
+			n := memDetailCounts[value.Name]
+			value.MemDetail = &container.MemDetail{
+				Rss:    twoDecimals(md.Rss / n),
+				Cache:  twoDecimals(md.Cache / n),
+				Mapped: twoDecimals(md.Mapped / n),
+			}
+		}
 		result = append(result, container.Stats{
 			Name:        value.Name,
-			Cpu:         twoDecimals(value.Cpu / count),
+			Type:        value.Type,
+			Image:       value.Image,
+			Cpu:         smallDecimals(value.Cpu / count),
 			Mem:         twoDecimals(value.Mem / count),
-			NetworkSent: twoDecimals(value.NetworkSent / count),
-			NetworkRecv: twoDecimals(value.NetworkRecv / count),
+			MemLimit:    value.MemLimit,
+			Health:      value.Health,
+			Restarts:    value.Restarts,
+			Uptime:      value.Uptime,
+			OomKills:    value.OomKills, // total, not averaged, so no kill is lost
+			MemPct:      twoDecimals(value.MemPct / count),
+			NetworkSent: smallDecimals(value.NetworkSent / count),
+			NetworkRecv: smallDecimals(value.NetworkRecv / count),
+			DiskReadPs:  smallDecimals(value.DiskReadPs / count),
+			DiskWritePs: smallDecimals(value.DiskWritePs / count),
+			SwapUsed:    twoDecimals(value.SwapUsed / count),
+			LogErrors:   twoDecimals(value.LogErrors / count),
+			Labels:      value.Labels,
+			CpuConfig:   value.CpuConfig,
+			Sockets:     value.Sockets,
+			MemDetail:   value.MemDetail,
+			Replicas:    value.Replicas,
+			Spread:      value.Spread,
 		})
 	}
 	return result
@@ -371,3 +647,11 @@ func (rm *RecordManager) DeleteOldRecords() {
 func twoDecimals(value float64) float64 {
 	return math.Round(value*100) / 100
 }
+
+// Same as twoDecimals, but keeps up to four decimals for values below 0.01
+func smallDecimals(value float64) float64 {
+	if math.Abs(value) >= 0.01 {
+		return twoDecimals(value)
+	}
+	return math.Round(value*10000) / 10000
+}